@@ -5,9 +5,35 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
 	"strings"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/protocol"
 )
 
+// commands are the lobby and battle commands typed by the user. Any other
+// input is sent as a move.
+var commands = map[string]bool{
+	"list":       true,
+	"queue":      true,
+	"challenge":  true,
+	"accept":     true,
+	"leave":      true,
+	"bot":        true,
+	"rules":      true,
+	"battles":    true,
+	"watch":      true,
+	"unwatch":    true,
+	"ladder":     true,
+	"history":    true,
+	"collection": true,
+	"switch":     true,
+	"quit":       true,
+}
+
+// maxTeamSize is the most Pokemon any battle format allows.
+const maxTeamSize = 6
+
 func main() {
 	startTCPClient()
 }
@@ -22,83 +48,176 @@ func startTCPClient() {
 	defer conn.Close()
 
 	fmt.Println("Connected to the TCP server.")
-	// Get the user's username
-	username := getUsernameFromInput()
+	reader := bufio.NewReader(os.Stdin)
 
-	// Send the username to the server
-	_, err = conn.Write([]byte(username))
-	if err != nil {
-		fmt.Println("Error writing to connection:", err)
-		return
+	// Log in, asking again until the server accepts the username and
+	// password
+	serverReader := protocol.NewReader(conn)
+	for !login(conn, serverReader, reader) {
 	}
 
 	// Start a goroutine to read responses from the server
-	go readResponsesFromServer(conn)
+	go readResponsesFromServer(serverReader)
 
 	// Read user input and send it to the server
-	readAndSendPokemons(conn, username)
-
-	// Read user battle inputs and send it to server
-	readAndSendBattle(conn, username)
+	readAndSendPokemons(conn, reader)
 
+	// Read lobby and battle commands and send them to the server
+	fmt.Println("Lobby commands: rules, list, queue, challenge <name>, accept <name>, bot [random|greedy|lookahead], battles, watch <name>, unwatch, collection, ladder, history, leave, team")
+	readAndSendBattle(conn, reader)
 }
 
-func getUsernameFromInput() string {
-	reader := bufio.NewReader(os.Stdin)
+func getUsernameFromInput(reader *bufio.Reader) string {
 	fmt.Print("Enter your username: ")
 	username, _ := reader.ReadString('\n')
 	return strings.TrimSpace(username)
 }
 
-func readAndSendPokemons(conn net.Conn, username string) {
-	reader := bufio.NewReader(os.Stdin)
-	for i := 1; i < 4; i++ {
+// login asks for the user's credentials, logging in or registering a new
+// account, and reports whether the server accepted them.
+func login(conn net.Conn, serverReader *protocol.Reader, reader *bufio.Reader) bool {
+	fmt.Print("Log in or register a new account? [login/register]: ")
+	choice, _ := reader.ReadString('\n')
+	msgType := protocol.TypeLogin
+	if strings.HasPrefix(strings.TrimSpace(choice), "r") {
+		msgType = protocol.TypeRegister
+	}
+	username := getUsernameFromInput(reader)
+	fmt.Print("Enter your password: ")
+	password, _ := reader.ReadString('\n')
+
+	err := protocol.Write(conn, protocol.Message{Type: msgType, Username: username, Password: strings.TrimSpace(password)})
+	if err != nil {
+		fmt.Println("Error writing to connection:", err)
+		os.Exit(1)
+	}
+
+	// The server either welcomes the user or says why it can't
+	msg, err := serverReader.Read()
+	if err != nil {
+		fmt.Println("Error reading from connection:", err)
+		os.Exit(1)
+	}
+	if msg.Type == protocol.TypeError {
+		fmt.Printf("Error: %s\n", msg.Error)
+		return false
+	}
+	fmt.Println(strings.TrimSpace(msg.Text))
+	return true
+}
+
+func readAndSendPokemons(conn net.Conn, reader *bufio.Reader) {
+	fmt.Println("Enter each Pokemon by name, optionally with a level and up to 4 moves (e.g. Charizard@50:flamethrower,slash).")
+	fmt.Println("Pokemon caught in pokeCat are picked by their number in your collection, e.g. #2:thunderbolt; type 'collection' in the lobby to list them.")
+	fmt.Println("Enter as many Pokemon as the server's format asks for, then an empty line.")
+	fmt.Println("Type 'exit' to skip, e.g. when resuming a battle after reconnecting.")
+	var team []protocol.TeamMember
+	for i := 1; i <= maxTeamSize; i++ {
 		fmt.Printf("Enter Pokemon %d: ", i)
 		text, _ := reader.ReadString('\n')
-		if strings.TrimSpace(text) == "exit" {
+		text = strings.TrimSpace(text)
+		if text == "exit" {
+			return
+		}
+		if text == "" {
 			break
 		}
 
-		// Append the username and Pokemon number to the input text
-		text = fmt.Sprintf("%s %d %s", username, i, strings.TrimSpace(text))
-
-		// Send the message to the server
-		_, err := conn.Write([]byte(text))
-		if err != nil {
-			fmt.Println("Error writing to connection:", err)
-			return
+		name, movesText, _ := strings.Cut(text, ":")
+		name, levelText, _ := strings.Cut(name, "@")
+		member := protocol.TeamMember{Name: strings.TrimSpace(name)}
+		if number, ok := strings.CutPrefix(member.Name, "#"); ok {
+			// A Pokemon from the user's pokeCat collection
+			caught, err := strconv.Atoi(number)
+			if err != nil || caught < 1 {
+				fmt.Println("Pick a caught Pokemon by its number, e.g. #2.")
+				i--
+				continue
+			}
+			member = protocol.TeamMember{Caught: caught}
+		}
+		if levelText != "" {
+			level, err := strconv.Atoi(strings.TrimSpace(levelText))
+			if err != nil {
+				fmt.Println("The level must be a number.")
+				i--
+				continue
+			}
+			member.Level = level
 		}
+		if movesText != "" {
+			for _, move := range strings.Split(movesText, ",") {
+				member.Moves = append(member.Moves, strings.TrimSpace(move))
+			}
+		}
+		team = append(team, member)
+	}
+	if len(team) == 0 {
+		return
+	}
+
+	// Send the team to the server
+	err := protocol.Write(conn, protocol.Message{Type: protocol.TypeTeam, Team: team})
+	if err != nil {
+		fmt.Println("Error writing to connection:", err)
 	}
 }
 
-func readAndSendBattle(conn net.Conn, username string) {
-	reader := bufio.NewReader(os.Stdin)
-	//buf := make([]byte, 1024)
+func readAndSendBattle(conn net.Conn, reader *bufio.Reader) {
 	for {
-		//fmt.Printf("Choose your next move (type in 'normal' or 'special'): ")
-		text, _ := reader.ReadString('\n')
-		text = fmt.Sprintf("%s %s a a", username, strings.TrimSpace(text))
+		// A move is chosen by its number or name in the moveset; anything
+		// else is a command such as "switch 2"
+		text, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if text == "team" {
+			readAndSendPokemons(conn, reader)
+			continue
+		}
+
+		command, arg, _ := strings.Cut(text, " ")
+		msg := protocol.Message{Type: protocol.TypeAction, Command: command, Arg: strings.TrimSpace(arg)}
+		if !commands[command] {
+			msg.Command, msg.Arg = "move", text
+		}
+
 		// Send the message to the server
-		_, err := conn.Write([]byte(text))
+		err = protocol.Write(conn, msg)
 		if err != nil {
 			fmt.Println("Error writing to connection:", err)
 			return
 		}
 	}
 }
-func readResponsesFromServer(conn net.Conn) {
-	buf := make([]byte, 1024)
+
+func readResponsesFromServer(reader *protocol.Reader) {
 	for {
-		n, err := conn.Read(buf)
+		msg, err := reader.Read()
+		if _, ok := protocol.IsMalformed(err); ok {
+			fmt.Println("Received a malformed message from the server:", err)
+			continue
+		}
 		if err != nil {
 			fmt.Println("Error reading from connection:", err)
-			return
+			os.Exit(1)
 		}
-		response := strings.TrimSpace(string(buf[:n]))
-		fmt.Println(response)
-		if strings.Contains(response, "The winner is") {
-			fmt.Println("Thank you for playing")
-			os.Exit(0)
+
+		switch msg.Type {
+		case protocol.TypeError:
+			fmt.Printf("Error: %s\n", msg.Error)
+			if msg.Code == protocol.ErrCodeIllegalTeam {
+				fmt.Println("Type 'team' to choose your team again.")
+			}
+		case protocol.TypeResult:
+			fmt.Println(strings.TrimSpace(msg.Text))
+			fmt.Println("Thank you for playing! Type 'queue' or 'challenge <name>' to battle again.")
+		default:
+			fmt.Println(strings.TrimSpace(msg.Text))
 		}
 	}
 }
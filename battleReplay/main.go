@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nguyensngoc108/pokemon-game/battleServer/usermanager"
+)
+
+func main() {
+	verify := flag.Bool("verify", false, "re-run the battle through the engine and check it plays out the same way")
+	delay := flag.Duration("delay", time.Second, "pause between rounds when showing a replay")
+	flag.StringVar(&usermanager.DataPath, "data", usermanager.DataPath, "directory holding the Pokemon data")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: battleReplay [-verify] [-delay d] <replay.jsonl>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Println("Error opening replay:", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	events, err := usermanager.ReadReplay(file)
+	if err != nil {
+		fmt.Println("Error reading replay:", err)
+		os.Exit(1)
+	}
+
+	if *verify {
+		winner, err := usermanager.VerifyReplay(events)
+		if err != nil {
+			fmt.Println("Replay does not match the engine:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Replay verified: %d events match and %s wins.\n", len(events), winner)
+		return
+	}
+	showReplay(events, *delay)
+}
+
+// showReplay prints a battle round by round as the players saw it.
+func showReplay(events []usermanager.ReplayEvent, delay time.Duration) {
+	for _, event := range events {
+		switch event.Type {
+		case usermanager.ReplayStart:
+			var names []string
+			for _, team := range event.Teams {
+				names = append(names, team.Username)
+			}
+			fmt.Printf("Replay of %s (seed %d)\n", strings.Join(names, " vs "), event.Seed)
+			for _, team := range event.Teams {
+				var members []string
+				for _, member := range team.Team {
+					members = append(members, fmt.Sprintf("%s (%s)", member.Name, strings.Join(member.Moves, ", ")))
+				}
+				owner := team.Username
+				if team.Bot != "" {
+					owner = fmt.Sprintf("%s, a %s bot,", team.Username, team.Bot)
+				}
+				fmt.Printf("%s brought %s\n", owner, strings.Join(members, ", "))
+			}
+		case usermanager.ReplayRound:
+			time.Sleep(delay)
+			fmt.Printf("\n--- Round %d ---\n%s\n", event.Round, strings.Join(event.Lines, "\n"))
+		case usermanager.ReplayKnockout:
+			fmt.Printf("%s's %s has been knocked out.\n", event.Username, event.Pokemon)
+		case usermanager.ReplayAction:
+			if event.Command == "quit" {
+				fmt.Printf("%s quit.\n", event.Username)
+			}
+		case usermanager.ReplayForfeit:
+			fmt.Printf("%s did not return in time and forfeits.\n", event.Username)
+		case usermanager.ReplayResult:
+			fmt.Printf("\nThe winner is %s!\n", event.Winner)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Package accounts keeps battleServer's registered trainers: their hashed
+// passwords, battle history and ladder rating. Accounts are saved as a JSON
+// file, in the spirit of pokeCatserver's players.json.
+package accounts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// InitialRating is the Elo rating of a new account.
+	InitialRating = 1000
+	// ratingK is the most a single battle can move a rating.
+	ratingK = 32
+	// minPasswordLength is the shortest password an account may have.
+	minPasswordLength = 4
+)
+
+var (
+	// ErrWrongPassword is returned when logging in with an unknown username
+	// or the wrong password. The two aren't told apart.
+	ErrWrongPassword = errors.New("wrong username or password")
+	// ErrTaken is returned when registering a username that already has an
+	// account.
+	ErrTaken = errors.New("username is already registered")
+)
+
+// Account is a registered trainer.
+type Account struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	Rating       int    `json:"rating"`
+	Wins         int    `json:"wins"`
+	Losses       int    `json:"losses"`
+	// History lists the account's rated battles, oldest first.
+	History []Result `json:"history,omitempty"`
+}
+
+// Result is the outcome of one rated battle for one account.
+type Result struct {
+	Opponent string    `json:"opponent"`
+	Won      bool      `json:"won"`
+	At       time.Time `json:"at"`
+	// Rating is the account's rating after the battle, and Change how much
+	// the battle moved it.
+	Rating int `json:"rating"`
+	Change int `json:"change"`
+}
+
+// Store holds every account and saves them to a file whenever one changes.
+// It is safe for concurrent use.
+type Store struct {
+	path     string
+	mu       sync.Mutex
+	accounts map[string]*Account
+}
+
+// Open loads the accounts saved at path. A missing file is an empty store.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, accounts: make(map[string]*Account)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var accounts []*Account
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, account := range accounts {
+		s.accounts[account.Username] = account
+	}
+	return s, nil
+}
+
+// Register creates an account with the given password.
+func (s *Store) Register(username, password string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("passwords must be at least %d characters", minPasswordLength)
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.accounts[username]; exists {
+		return ErrTaken
+	}
+	s.accounts[username] = &Account{Username: username, PasswordHash: hash, Rating: InitialRating}
+	return s.save()
+}
+
+// Login checks a user's password.
+func (s *Store) Login(username, password string) error {
+	s.mu.Lock()
+	account, exists := s.accounts[username]
+	var hash string
+	if exists {
+		hash = account.PasswordHash
+	}
+	s.mu.Unlock()
+
+	// Hashing is slow on purpose, so it happens outside the lock
+	if !exists || !checkPassword(hash, password) {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// Get returns a copy of a user's account.
+func (s *Store) Get(username string) (Account, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	account, exists := s.accounts[username]
+	if !exists {
+		return Account{}, false
+	}
+	return account.copy(), true
+}
+
+// RecordBattle adds a rated battle to both accounts' history and moves their
+// ratings, returning the winner's and loser's results.
+func (s *Store) RecordBattle(winner, loser string, at time.Time) (Result, Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, ok := s.accounts[winner]
+	if !ok {
+		return Result{}, Result{}, fmt.Errorf("%s has no account", winner)
+	}
+	l, ok := s.accounts[loser]
+	if !ok {
+		return Result{}, Result{}, fmt.Errorf("%s has no account", loser)
+	}
+
+	change := ratingChange(w.Rating, l.Rating)
+	w.Rating += change
+	l.Rating -= change
+	w.Wins++
+	l.Losses++
+	won := Result{Opponent: loser, Won: true, At: at, Rating: w.Rating, Change: change}
+	lost := Result{Opponent: winner, At: at, Rating: l.Rating, Change: -change}
+	w.History = append(w.History, won)
+	l.History = append(l.History, lost)
+	return won, lost, s.save()
+}
+
+// Ladder returns up to n accounts with the highest ratings, best first. Ties
+// go to the account with more wins, then by username.
+func (s *Store) Ladder(n int) []Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ladder []Account
+	for _, account := range s.accounts {
+		ladder = append(ladder, account.copy())
+	}
+	sort.Slice(ladder, func(i, j int) bool {
+		a, b := ladder[i], ladder[j]
+		if a.Rating != b.Rating {
+			return a.Rating > b.Rating
+		}
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		return a.Username < b.Username
+	})
+	if len(ladder) > n {
+		ladder = ladder[:n]
+	}
+	return ladder
+}
+
+// ratingChange is the Elo rating the winner takes from the loser: more for
+// beating a stronger player, less for beating a weaker one, and at least 1.
+func ratingChange(winner, loser int) int {
+	expected := 1 / (1 + math.Pow(10, float64(loser-winner)/400))
+	change := int(math.Round(ratingK * (1 - expected)))
+	if change < 1 {
+		return 1
+	}
+	return change
+}
+
+// save writes every account to the store's file. It replaces the file in one
+// step, so a crash never leaves it half written. Callers hold the lock.
+func (s *Store) save() error {
+	accounts := make([]*Account, 0, len(s.accounts))
+	for _, account := range s.accounts {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Username < accounts[j].Username })
+	data, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (a *Account) copy() Account {
+	c := *a
+	c.History = append([]Result(nil), a.History...)
+	return c
+}
@@ -0,0 +1,124 @@
+package accounts
+
+import (
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_pbkdf2(t *testing.T) {
+	// Test vector from RFC 7914, section 11
+	got := hex.EncodeToString(pbkdf2([]byte("passwd"), []byte("salt"), 1, 64))
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if got != want {
+		t.Errorf("pbkdf2() = %s, want %s", got, want)
+	}
+}
+
+func TestStore_Login(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "accounts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Register("ash", "pikachu"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantErr  error
+	}{
+		{name: "right password", username: "ash", password: "pikachu"},
+		{name: "wrong password", username: "ash", password: "raichu", wantErr: ErrWrongPassword},
+		{name: "unknown user", username: "gary", password: "pikachu", wantErr: ErrWrongPassword},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store.Login(tt.username, tt.password); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Login() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := store.Register("ash", "squirtle"); !errors.Is(err, ErrTaken) {
+		t.Errorf("Register() of a taken name error = %v, want %v", err, ErrTaken)
+	}
+	if err := store.Register("misty", "abc"); err == nil {
+		t.Error("Register() accepted a 3 character password")
+	}
+	account, _ := store.Get("ash")
+	if account.PasswordHash == "pikachu" {
+		t.Error("the password was stored as plain text")
+	}
+}
+
+func Test_ratingChange(t *testing.T) {
+	tests := []struct {
+		name   string
+		winner int
+		loser  int
+		want   int
+	}{
+		{name: "even players", winner: 1000, loser: 1000, want: 16},
+		{name: "upset", winner: 1000, loser: 1400, want: 29},
+		{name: "expected win", winner: 1400, loser: 1000, want: 3},
+		{name: "never less than 1", winner: 3000, loser: 1000, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ratingChange(tt.winner, tt.loser); got != tt.want {
+				t.Errorf("ratingChange(%d, %d) = %d, want %d", tt.winner, tt.loser, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_RecordBattle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ash", "gary", "misty"} {
+		if err := store.Register(name, "password"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if _, _, err := store.RecordBattle("ash", "gary", at); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.RecordBattle("ash", "misty", at); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.RecordBattle("ash", "brock", at); err == nil {
+		t.Error("RecordBattle() rated a battle against a user with no account")
+	}
+
+	// Everything survives reopening the store
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ladder := reopened.Ladder(2)
+	if len(ladder) != 2 || ladder[0].Username != "ash" || ladder[1].Username != "misty" {
+		t.Fatalf("Ladder(2) = %+v, want ash then misty", ladder)
+	}
+	ash := ladder[0]
+	if ash.Wins != 2 || ash.Losses != 0 || len(ash.History) != 2 {
+		t.Errorf("ash has %d wins, %d losses and %d results, want 2, 0 and 2", ash.Wins, ash.Losses, len(ash.History))
+	}
+	if ash.Rating != InitialRating+16+15 {
+		t.Errorf("ash's rating = %d, want %d", ash.Rating, InitialRating+16+15)
+	}
+	if gary, _ := reopened.Get("gary"); gary.Rating != InitialRating-16 || gary.History[0].Opponent != "ash" || gary.History[0].Won {
+		t.Errorf("gary = %+v, want a loss to ash rated %d", gary, InitialRating-16)
+	}
+	if err := reopened.Login("misty", "password"); err != nil {
+		t.Errorf("Login() after reopening error = %v", err)
+	}
+}
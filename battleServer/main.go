@@ -1,18 +1,58 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"net"
-	"strconv"
+	"os"
 	"strings"
+	"time"
+
+	"github.com/nguyensngoc108/pokemon-game/battleServer/accounts"
 	"github.com/nguyensngoc108/pokemon-game/battleServer/usermanager"
+	"github.com/nguyensngoc108/pokemon-game/internal/protocol"
 )
 
+// idleTimeout is how long a connection may go without sending anything before
+// it is treated as disconnected.
+var idleTimeout = 10 * time.Minute
+
 func main() {
-	startTCPServer()
+	reconnectGrace := flag.Duration("reconnect-grace", usermanager.DefaultReconnectGrace, "how long a disconnected player's seat in a battle is held")
+	flag.DurationVar(&idleTimeout, "idle-timeout", idleTimeout, "how long a connection may stay silent before it is dropped")
+	replayDir := flag.String("replay-dir", "replays", "directory battle replay logs are written to, or empty to keep none")
+	format := flag.String("format", usermanager.DefaultFormat, "battle format whose team rules players must follow")
+	formats := flag.String("formats", "", "JSON file of extra battle formats to choose from")
+	collections := flag.String("pokecat-players", "../pokeCatserver/players.json", "pokeCatserver's players.json, whose caught Pokemon trainers may battle with, or empty to allow species names only")
+	accountsFile := flag.String("accounts", "accounts.json", "file trainer accounts and ratings are kept in, or empty to let anyone log in unrated")
+	flag.Parse()
+
+	if *formats != "" {
+		if err := usermanager.LoadFormats(*formats); err != nil {
+			fmt.Println("Error loading battle formats:", err)
+			os.Exit(2)
+		}
+	}
+
+	ruleset, ok := usermanager.Formats[*format]
+	if !ok {
+		fmt.Println("Unknown battle format:", *format)
+		os.Exit(2)
+	}
+	var store *accounts.Store
+	if *accountsFile != "" {
+		var err error
+		store, err = accounts.Open(*accountsFile)
+		if err != nil {
+			fmt.Println("Error loading accounts:", err)
+			os.Exit(2)
+		}
+	}
+	startTCPServer(*reconnectGrace, *replayDir, ruleset, store, *collections)
 }
 
-func startTCPServer() {
+func startTCPServer(reconnectGrace time.Duration, replayDir string, ruleset *usermanager.Ruleset, store *accounts.Store, collections string) {
 	// Listen on TCP port
 	listener, err := net.Listen("tcp", ":8000")
 	if err != nil {
@@ -20,9 +60,13 @@ func startTCPServer() {
 		return
 	}
 	defer listener.Close()
-	userManager := usermanager.NewUserManager()
-	usermanager.SetUserManagerInstance(userManager)
-	fmt.Println("TCP server listening on :8000")
+	lobby := usermanager.NewLobby()
+	lobby.ReconnectGrace = reconnectGrace
+	lobby.ReplayDir = replayDir
+	lobby.Ruleset = ruleset
+	lobby.Accounts = store
+	lobby.Collections = collections
+	fmt.Printf("TCP server listening on :8000, playing %s\n", ruleset.Describe())
 
 	for {
 		// Wait for a connection
@@ -33,58 +77,88 @@ func startTCPServer() {
 		}
 
 		// Handle the connection in a new goroutine
-		go handleConnection(conn, userManager)
+		go handleConnection(conn, lobby)
 	}
 }
 
-func handleConnection(conn net.Conn, userManager *usermanager.UserManager) {
+func handleConnection(conn net.Conn, lobby *usermanager.Lobby) {
 	defer conn.Close()
-	// Store the userManager instance in the singleton
-	usermanager.GetUserManagerInstance().Users = userManager.Users
-	buf := make([]byte, 1024)
+	// The username this connection logged in as
+	var username string
+	defer func() {
+		if username != "" {
+			lobby.Disconnect(username, conn)
+		}
+	}()
+
+	reader := protocol.NewReader(conn)
 	for {
-		// Read data from the connection
-		n, err := conn.Read(buf)
+		// Read the next message from the connection, dropping it when it
+		// stays silent too long
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		msg, err := reader.Read()
+		if malformed, ok := protocol.IsMalformed(err); ok {
+			sendError(conn, malformed.Code, malformed.Err)
+			continue
+		}
 		if err != nil {
 			fmt.Println("Error reading from connection:", err)
 			return
 		}
 
-		// Parse the received data
-		data := strings.TrimSpace(string(buf[:n]))
-		parts := strings.Split(data, " ")
+		if username == "" && msg.Type != protocol.TypeLogin && msg.Type != protocol.TypeRegister {
+			sendError(conn, protocol.ErrCodeInvalid, fmt.Errorf("log in first"))
+			continue
+		}
 
-		switch len(parts) {
-		case 1:
-			// New pokeDexClient connection
-			username := parts[0]
-			user := userManager.AddUser(username, conn)
-			fmt.Printf("New pokeDexClient connected: %s\n", user.Username)
-		case 3:
+		switch msg.Type {
+		case protocol.TypeLogin, protocol.TypeRegister:
+			// New battleClient connection
+			if username != "" {
+				sendError(conn, protocol.ErrCodeInvalid, fmt.Errorf("already logged in as %s", username))
+				continue
+			}
+			name := strings.TrimSpace(msg.Username)
+			if err := lobby.Authenticate(name, msg.Password, msg.Type == protocol.TypeRegister); err != nil {
+				code := protocol.ErrCodeInvalid
+				if errors.Is(err, accounts.ErrWrongPassword) {
+					code = protocol.ErrCodeAuth
+				}
+				sendError(conn, code, err)
+				continue
+			}
+			user, err := lobby.AddUser(name, conn)
+			if err != nil {
+				sendError(conn, protocol.ErrCodeInvalid, err)
+				continue
+			}
+			username = user.Username
+			fmt.Printf("New battleClient connected: %s\n", user.Username)
+		case protocol.TypeTeam:
 			// Received Pokemon information
-			username := parts[0]
-			pokemonNumber, _ := strconv.Atoi(parts[1])
-			pokemonName := parts[2]
-
-			err := userManager.UpdatePokemonData(username, pokemonName, pokemonNumber)
+			err := lobby.UpdateTeam(username, msg.Team)
 			if err != nil {
 				fmt.Printf("Error updating Pokemon data for %s: %v\n", username, err)
+				code := protocol.ErrCodeInvalid
+				var teamErr *usermanager.TeamError
+				if errors.As(err, &teamErr) {
+					code = protocol.ErrCodeIllegalTeam
+				}
+				sendError(conn, code, err)
 				continue
 			}
-
-			userManager.UpdatePokemons(username, pokemonName, pokemonNumber)
-			fmt.Printf("%s added Pokemon %d: %s\n", username, pokemonNumber, pokemonName)
-
-			if userManager.AllPokemonsProvided() && len(userManager.Users) == 2 {
-				userManager.StartBattle()
-			}
-		case 4:
-			if userManager.AllPokemonsProvided() {
-				moveType := parts[1]
-				username := parts[0]
-				// Process the move information and send the result back to the clients
-				userManager.PerformBattle(moveType, username)
-			}
+			fmt.Printf("%s chose their team\n", username)
+		case protocol.TypeAction:
+			// Lobby commands, or battle commands once the user is in a battle
+			lobby.HandleCommand(username, msg.Command, msg.Arg)
+		default:
+			sendError(conn, protocol.ErrCodeUnknownType, fmt.Errorf("%s messages are only sent by the server", msg.Type))
 		}
 	}
 }
+
+func sendError(conn net.Conn, code string, err error) {
+	if err := protocol.Write(conn, protocol.ErrorMessage(code, err)); err != nil {
+		fmt.Println("Error writing to connection:", err)
+	}
+}
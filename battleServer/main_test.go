@@ -0,0 +1,418 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nguyensngoc108/pokemon-game/battleServer/accounts"
+	"github.com/nguyensngoc108/pokemon-game/battleServer/usermanager"
+	"github.com/nguyensngoc108/pokemon-game/internal/protocol"
+)
+
+// moveLine matches a move with PP left, e.g. "  1. Thunderbolt (electric) PP 15/15".
+var moveLine = regexp.MustCompile(`(?m)^\s+(\d+)\. .+ \(\w+\) PP [1-9]`)
+
+// benchLine matches a Pokemon that can still be sent in, e.g. "  2. Blastoise 79/79 HP".
+var benchLine = regexp.MustCompile(`(?m)^\s+(\d+)\. .* \d+/\d+ HP( \[[A-Z]+\])?$`)
+
+// testClient plays a battle over a net.Pipe connection, always using its first
+// move with PP left and sending in the first Pokemon that can still battle.
+type testClient struct {
+	username string
+	conn     net.Conn
+	outgoing chan protocol.Message
+	result   chan *protocol.Message
+	// dropOnPrompt makes the client hang up when first asked for a move.
+	dropOnPrompt bool
+	dropped      chan struct{}
+	// moveDelay is how long the client thinks before choosing a move.
+	moveDelay time.Duration
+	// hold, when set, keeps the client from choosing a move until it is
+	// closed.
+	hold chan struct{}
+
+	mu       sync.Mutex
+	messages []protocol.Message
+}
+
+func newTestClient(t *testing.T, lobby *usermanager.Lobby, username string) *testClient {
+	return startTestClient(t, lobby, username, false)
+}
+
+func startTestClient(t *testing.T, lobby *usermanager.Lobby, username string, dropOnPrompt bool) *testClient {
+	return connectTestClient(t, lobby, &testClient{username: username, dropOnPrompt: dropOnPrompt})
+}
+
+// connectTestClient connects a client to the lobby and starts it playing.
+func connectTestClient(t *testing.T, lobby *usermanager.Lobby, c *testClient) *testClient {
+	serverConn, clientConn := net.Pipe()
+	go handleConnection(serverConn, lobby)
+
+	c.conn = clientConn
+	c.outgoing = make(chan protocol.Message, 64)
+	c.result = make(chan *protocol.Message, 1)
+	c.dropped = make(chan struct{})
+	t.Cleanup(func() { clientConn.Close() })
+
+	// Writes go through their own goroutine so reading the server's messages
+	// never waits on a write, which would deadlock an unbuffered pipe
+	go func() {
+		for msg := range c.outgoing {
+			if err := protocol.Write(clientConn, msg); err != nil {
+				return
+			}
+		}
+	}()
+	go c.play()
+	return c
+}
+
+func (c *testClient) send(msg protocol.Message) {
+	c.outgoing <- msg
+}
+
+func (c *testClient) play() {
+	reader := protocol.NewReader(c.conn)
+	for {
+		msg, err := reader.Read()
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		c.messages = append(c.messages, *msg)
+		c.mu.Unlock()
+
+		switch {
+		case msg.Type == protocol.TypeResult:
+			// Keep reading so the server is never left blocked writing
+			// to the pipe
+			c.result <- msg
+		case strings.Contains(msg.Text, "Choose your next move") && c.dropOnPrompt:
+			c.conn.Close()
+			close(c.dropped)
+			return
+		case strings.Contains(msg.Text, "Choose your next move"):
+			if c.hold != nil {
+				<-c.hold
+			}
+			time.Sleep(c.moveDelay)
+			// Once every move is out of PP any choice makes the Pokemon Struggle
+			move := "1"
+			if match := moveLine.FindStringSubmatch(msg.Text); match != nil {
+				move = match[1]
+			}
+			c.send(protocol.Message{Type: protocol.TypeAction, Command: "move", Arg: move})
+		case strings.Contains(msg.Text, "Choose a Pokemon to send in"):
+			if match := benchLine.FindStringSubmatch(msg.Text); match != nil {
+				c.send(protocol.Message{Type: protocol.TypeAction, Command: "switch", Arg: match[1]})
+			}
+		}
+	}
+}
+
+func TestConcurrentBattles(t *testing.T) {
+	const pairs = 8
+	lobby := usermanager.NewLobby()
+	team := []protocol.TeamMember{{Name: "Pikachu"}, {Name: "Bulbasaur"}, {Name: "Squirtle"}}
+
+	var clients []*testClient
+	for i := 0; i < pairs*2; i++ {
+		c := newTestClient(t, lobby, fmt.Sprintf("trainer %d", i))
+		c.send(protocol.Message{Type: protocol.TypeLogin, Username: c.username})
+		c.send(protocol.Message{Type: protocol.TypeTeam, Team: team})
+		c.send(protocol.Message{Type: protocol.TypeAction, Command: "queue"})
+		clients = append(clients, c)
+	}
+
+	var wg sync.WaitGroup
+	winners := make(chan string, len(clients))
+	for _, c := range clients {
+		wg.Add(1)
+		go func(c *testClient) {
+			defer wg.Done()
+			select {
+			case msg := <-c.result:
+				winners <- msg.Winner
+			case <-time.After(30 * time.Second):
+				t.Errorf("%s never finished their battle", c.username)
+			}
+		}(c)
+	}
+	wg.Wait()
+	close(winners)
+
+	// Both players of a battle hear the same winner, so every winner is
+	// reported exactly twice
+	counts := make(map[string]int)
+	for winner := range winners {
+		counts[winner]++
+	}
+	if len(counts) != pairs {
+		t.Errorf("got %d winners, want %d: %v", len(counts), pairs, counts)
+	}
+	for winner, n := range counts {
+		if n != 2 {
+			t.Errorf("winner %s was announced %d times, want 2", winner, n)
+		}
+	}
+}
+
+// received reports whether the client was sent a message containing text.
+func (c *testClient) received(text string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, msg := range c.messages {
+		if strings.Contains(msg.Text, text) || strings.Contains(msg.Error, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstMessage returns the first message the client was sent whose text
+// starts with prefix.
+func (c *testClient) firstMessage(prefix string) (protocol.Message, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, msg := range c.messages {
+		if strings.HasPrefix(msg.Text, prefix) {
+			return msg, true
+		}
+	}
+	return protocol.Message{}, false
+}
+
+// count returns how many messages the client was sent with exactly the text.
+func (c *testClient) count(text string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, msg := range c.messages {
+		if msg.Text == text {
+			n++
+		}
+	}
+	return n
+}
+
+func waitForResult(t *testing.T, c *testClient) *protocol.Message {
+	t.Helper()
+	select {
+	case msg := <-c.result:
+		return msg
+	case <-time.After(30 * time.Second):
+		t.Fatalf("%s never finished their battle", c.username)
+		return nil
+	}
+}
+
+func TestDisconnectForfeits(t *testing.T) {
+	lobby := usermanager.NewLobby()
+	lobby.ReconnectGrace = 100 * time.Millisecond
+	team := []protocol.TeamMember{{Name: "Pikachu"}, {Name: "Bulbasaur"}, {Name: "Squirtle"}}
+
+	leaver := startTestClient(t, lobby, "leaver", true)
+	stayer := newTestClient(t, lobby, "stayer")
+	for _, c := range []*testClient{leaver, stayer} {
+		c.send(protocol.Message{Type: protocol.TypeLogin, Username: c.username})
+		c.send(protocol.Message{Type: protocol.TypeTeam, Team: team})
+		c.send(protocol.Message{Type: protocol.TypeAction, Command: "queue"})
+	}
+
+	if got := waitForResult(t, stayer).Winner; got != "stayer" {
+		t.Errorf("winner = %s, want stayer", got)
+	}
+	if !stayer.received("leaver disconnected") {
+		t.Error("stayer was not told leaver disconnected")
+	}
+}
+
+func TestReconnectResumesBattle(t *testing.T) {
+	lobby := usermanager.NewLobby()
+	lobby.ReconnectGrace = 10 * time.Second
+	team := []protocol.TeamMember{{Name: "Pikachu"}, {Name: "Bulbasaur"}, {Name: "Squirtle"}}
+
+	flaky := startTestClient(t, lobby, "flaky", true)
+	steady := newTestClient(t, lobby, "steady")
+	for _, c := range []*testClient{flaky, steady} {
+		c.send(protocol.Message{Type: protocol.TypeLogin, Username: c.username})
+		c.send(protocol.Message{Type: protocol.TypeTeam, Team: team})
+		c.send(protocol.Message{Type: protocol.TypeAction, Command: "queue"})
+	}
+
+	select {
+	case <-flaky.dropped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("flaky never got to choose a move")
+	}
+
+	// Logging in again with the same username picks the battle back up
+	var back *testClient
+	deadline := time.Now().Add(10 * time.Second)
+	for back == nil || !back.received("Reconnected") {
+		if time.Now().After(deadline) {
+			t.Fatal("flaky could not reconnect")
+		}
+		back = newTestClient(t, lobby, "flaky")
+		back.send(protocol.Message{Type: protocol.TypeLogin, Username: "flaky"})
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	winner := waitForResult(t, back).Winner
+	if got := waitForResult(t, steady).Winner; got != winner {
+		t.Errorf("players saw different winners: %s and %s", winner, got)
+	}
+	if !steady.received("flaky reconnected") {
+		t.Error("steady was not told flaky reconnected")
+	}
+}
+
+func TestBotBattle(t *testing.T) {
+	lobby := usermanager.NewLobby()
+	team := []protocol.TeamMember{{Name: "Pikachu"}, {Name: "Bulbasaur"}, {Name: "Squirtle"}}
+
+	c := newTestClient(t, lobby, "solo")
+	c.send(protocol.Message{Type: protocol.TypeLogin, Username: c.username})
+	c.send(protocol.Message{Type: protocol.TypeTeam, Team: team})
+	c.send(protocol.Message{Type: protocol.TypeAction, Command: "bot", Arg: usermanager.DifficultyLookahead})
+
+	if got := waitForResult(t, c).Winner; got != "solo" && got != "CPU" {
+		t.Errorf("winner = %s, want solo or CPU", got)
+	}
+}
+
+func TestDoublesBattle(t *testing.T) {
+	lobby := usermanager.NewLobby()
+	lobby.Ruleset = usermanager.Formats["doubles"]
+	team := []protocol.TeamMember{{Name: "Pikachu"}, {Name: "Bulbasaur"}, {Name: "Squirtle"}, {Name: "Charmander"}}
+
+	var clients []*testClient
+	for _, name := range []string{"left", "right"} {
+		c := newTestClient(t, lobby, name)
+		c.send(protocol.Message{Type: protocol.TypeLogin, Username: c.username})
+		c.send(protocol.Message{Type: protocol.TypeTeam, Team: team})
+		c.send(protocol.Message{Type: protocol.TypeAction, Command: "queue"})
+		clients = append(clients, c)
+	}
+
+	winner := waitForResult(t, clients[0]).Winner
+	if got := waitForResult(t, clients[1]).Winner; got != winner {
+		t.Errorf("players saw different winners: %s and %s", winner, got)
+	}
+	if !clients[0].received("(position 2)") {
+		t.Error("left was never asked for the action of its second Pokemon")
+	}
+}
+
+func TestRatedBattle(t *testing.T) {
+	store, err := accounts.Open(filepath.Join(t.TempDir(), "accounts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lobby := usermanager.NewLobby()
+	lobby.Accounts = store
+	team := []protocol.TeamMember{{Name: "Pikachu"}, {Name: "Bulbasaur"}, {Name: "Squirtle"}}
+
+	var players []*testClient
+	for _, name := range []string{"ash", "gary"} {
+		c := newTestClient(t, lobby, name)
+		c.send(protocol.Message{Type: protocol.TypeRegister, Username: name, Password: name + "-secret"})
+		c.send(protocol.Message{Type: protocol.TypeTeam, Team: team})
+		c.send(protocol.Message{Type: protocol.TypeAction, Command: "queue"})
+		players = append(players, c)
+	}
+	winner := waitForResult(t, players[0]).Winner
+	waitForResult(t, players[1])
+
+	// The ratings are updated once the battle has wound down
+	deadline := time.Now().Add(10 * time.Second)
+	for !players[0].received("Your rating is now") {
+		if time.Now().After(deadline) {
+			t.Fatal("ash was never told their new rating")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	ladder := store.Ladder(10)
+	if len(ladder) != 2 || ladder[0].Username != winner || ladder[0].Rating != accounts.InitialRating+16 {
+		t.Errorf("ladder = %+v, want %s first at %d", ladder, winner, accounts.InitialRating+16)
+	}
+
+	// A wrong password is turned away
+	intruder := newTestClient(t, lobby, "intruder")
+	intruder.send(protocol.Message{Type: protocol.TypeLogin, Username: "ash", Password: "guess"})
+	for !intruder.received(accounts.ErrWrongPassword.Error()) {
+		if time.Now().After(deadline) {
+			t.Fatal("logging in with the wrong password was not refused")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSpectator(t *testing.T) {
+	lobby := usermanager.NewLobby()
+	team := []protocol.TeamMember{{Name: "Pikachu"}, {Name: "Bulbasaur"}, {Name: "Squirtle"}}
+
+	// The players wait for the watcher before their first move, so it joins
+	// at the start of round 1
+	hold := make(chan struct{})
+	var players []*testClient
+	for _, username := range []string{"ash", "gary"} {
+		c := connectTestClient(t, lobby, &testClient{username: username, hold: hold})
+		c.send(protocol.Message{Type: protocol.TypeLogin, Username: c.username})
+		c.send(protocol.Message{Type: protocol.TypeTeam, Team: team})
+		c.send(protocol.Message{Type: protocol.TypeAction, Command: "queue"})
+		players = append(players, c)
+	}
+
+	// Spectators don't need a team. Keep asking until the battle has started
+	watcher := newTestClient(t, lobby, "watcher")
+	watcher.send(protocol.Message{Type: protocol.TypeLogin, Username: watcher.username})
+	deadline := time.Now().Add(10 * time.Second)
+	for !watcher.received("You are watching") {
+		if time.Now().After(deadline) {
+			t.Fatal("watcher never got to watch the battle")
+		}
+		watcher.send(protocol.Message{Type: protocol.TypeAction, Command: "watch", Arg: "ash"})
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Only the Pokemon sent out are shown, never the rest of the teams
+	const want = "You are watching ash vs gary, round 1. Type 'unwatch' to stop.\n" +
+		"ash's team:\n  Pikachu 110/110 HP (active)\n  2 not yet seen\n" +
+		"gary's team:\n  Pikachu 110/110 HP (active)\n  2 not yet seen\n"
+	if got, _ := watcher.firstMessage("You are watching"); got.Type != protocol.TypeState || got.Text != want {
+		t.Errorf("watcher was sent a %s message %q, want a state message %q", got.Type, got.Text, want)
+	}
+
+	// Spectators can't take part in the battle
+	for _, command := range []string{"move", "switch", "quit"} {
+		watcher.send(protocol.Message{Type: protocol.TypeAction, Command: command, Arg: "2"})
+	}
+	const rejected = "You are only watching this battle. Type 'unwatch' to stop.\n"
+	for deadline := time.Now().Add(10 * time.Second); watcher.count(rejected) < 3; {
+		if time.Now().After(deadline) {
+			t.Fatalf("watcher's commands were not all rejected, got %d", watcher.count(rejected))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, player := range players {
+		if player.received("--- Round") {
+			t.Errorf("%s saw a round played before either player moved", player.username)
+		}
+	}
+
+	close(hold)
+	winner := waitForResult(t, players[0]).Winner
+	if got := waitForResult(t, watcher).Winner; got != winner {
+		t.Errorf("watcher saw %s win, player saw %s", got, winner)
+	}
+	if !watcher.received("--- Round 1 ---") {
+		t.Error("watcher was not sent the rounds")
+	}
+}
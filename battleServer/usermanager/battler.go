@@ -0,0 +1,115 @@
+package usermanager
+
+import "fmt"
+
+// battler is one of a user's positions on the field: 1 in singles, 2 in
+// doubles.
+type battler struct {
+	user     *User
+	position int
+}
+
+// pokemon returns the Pokemon in the position, or nil once it is empty.
+func (b battler) pokemon() *PokemonData {
+	return b.user.Active[b.position]
+}
+
+// alive reports whether the position holds a Pokemon that can still battle.
+func (b battler) alive() bool {
+	pokemon := b.pokemon()
+	return pokemon != nil && pokemon.CurrentHP > 0
+}
+
+func (b battler) action() *Action {
+	return b.user.PendingActions[b.position]
+}
+
+// name names the Pokemon for the round summary, e.g. "ash's Pikachu".
+func (b battler) name() string {
+	return fmt.Sprintf("%s's %s", b.user.Username, b.pokemon().Monster.Name)
+}
+
+// battlers returns every position of the user's side of the field.
+func (u *User) battlers() []battler {
+	var battlers []battler
+	for position := range u.Active {
+		battlers = append(battlers, battler{user: u, position: position})
+	}
+	return battlers
+}
+
+// choosing returns the position the user has to choose an action for next, or
+// -1 once every Pokemon still in battle has one.
+func (u *User) choosing() int {
+	for _, b := range u.battlers() {
+		if b.alive() && b.action() == nil {
+			return b.position
+		}
+	}
+	return -1
+}
+
+// isActive reports whether the Pokemon is in one of the user's positions.
+func (u *User) isActive(pokemon *PokemonData) bool {
+	for _, active := range u.Active {
+		if active == pokemon {
+			return true
+		}
+	}
+	return false
+}
+
+// switchable reports whether the Pokemon can be sent in: it can still battle,
+// isn't already in battle and isn't about to be sent in by another position.
+func (u *User) switchable(pokemon *PokemonData) bool {
+	if pokemon.CurrentHP <= 0 || u.isActive(pokemon) {
+		return false
+	}
+	for _, action := range u.PendingActions {
+		if action != nil && action.SwitchTo == pokemon {
+			return false
+		}
+	}
+	return true
+}
+
+// bench returns the Pokemon that could be sent in.
+func (u *User) bench() []*PokemonData {
+	var bench []*PokemonData
+	for _, pokemon := range u.PokemonData {
+		if u.switchable(pokemon) {
+			bench = append(bench, pokemon)
+		}
+	}
+	return bench
+}
+
+// faintedPositions returns the positions whose Pokemon has fainted and not yet
+// been replaced.
+func (u *User) faintedPositions() []int {
+	var positions []int
+	for _, b := range u.battlers() {
+		if pokemon := b.pokemon(); pokemon != nil && pokemon.CurrentHP <= 0 {
+			positions = append(positions, b.position)
+		}
+	}
+	return positions
+}
+
+// target returns the opponent's position a move aimed at the given position
+// hits. When that Pokemon has already fainted the move goes to another one,
+// and it fails when none are left.
+func (um *UserManager) target(attacker battler, position int) (battler, bool) {
+	opponent := um.getOpponent(attacker.user.Username)
+	if position >= 0 && position < len(opponent.Active) {
+		if b := (battler{user: opponent, position: position}); b.alive() {
+			return b, true
+		}
+	}
+	for _, b := range opponent.battlers() {
+		if b.alive() {
+			return b, true
+		}
+	}
+	return battler{}, false
+}
@@ -0,0 +1,363 @@
+package usermanager
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+	"github.com/nguyensngoc108/pokemon-game/internal/protocol"
+	"github.com/nguyensngoc108/pokemon-game/utils"
+)
+
+// Difficulty levels of a bot.
+const (
+	// DifficultyRandom picks any move with PP left.
+	DifficultyRandom = "random"
+	// DifficultyGreedy picks the move expected to do the most damage.
+	DifficultyGreedy = "greedy"
+	// DifficultyLookahead weighs the damage it expects to deal against the
+	// damage it expects to take this round, and switches out of bad
+	// matchups.
+	DifficultyLookahead = "lookahead"
+)
+
+const (
+	// averageDamageRoll is the mean of the random 85..100 percent roll.
+	averageDamageRoll = float64(minDamageRoll+100) / 200
+	// switchPenalty is what a lookahead bot gives up by switching instead of
+	// attacking.
+	switchPenalty   = 0.1
+	maxTeamAttempts = 50
+)
+
+// Bot plays one side of a battle for the server.
+type Bot struct {
+	Difficulty string
+}
+
+// ValidDifficulty reports whether a bot can play at the given difficulty.
+func ValidDifficulty(difficulty string) bool {
+	switch difficulty {
+	case DifficultyRandom, DifficultyGreedy, DifficultyLookahead:
+		return true
+	}
+	return false
+}
+
+// NewBotUser creates a bot with a random team that is legal in the ruleset,
+// using each species' default moveset.
+func NewBotUser(username, difficulty string, ruleset *Ruleset, rng *rand.Rand) (*User, error) {
+	if !ValidDifficulty(difficulty) {
+		return nil, fmt.Errorf("unknown difficulty %s", difficulty)
+	}
+
+	var species []string
+	for name, id := range utils.PokeMap {
+		nationalID, _ := strconv.Atoi(id)
+		if ruleset.allowedGeneration(nationalID) && !ruleset.banned(name) {
+			species = append(species, name)
+		}
+	}
+	// Sort before shuffling so the same seed always picks the same team
+	sort.Strings(species)
+	rng.Shuffle(len(species), func(i, j int) { species[i], species[j] = species[j], species[i] })
+
+	bot := &User{Username: username, Pokemons: make([]string, ruleset.TeamSize), Bot: &Bot{Difficulty: difficulty}}
+	for i := 0; i < len(species) && i < maxTeamAttempts && !bot.TeamComplete(); i++ {
+		// Some species have no data or moves to battle with
+		pokemon, err := loadPokemon(protocol.TeamMember{Name: species[i], Level: ruleset.DefaultLevel()})
+		if err != nil || len(ruleset.problems(append(bot.PokemonData, pokemon))) > 0 {
+			continue
+		}
+		slot := len(bot.PokemonData) + 1
+		bot.PokemonData = append(bot.PokemonData, pokemon)
+		bot.UpdatePokemons(pokemon.Monster.Name, slot)
+	}
+	if !bot.TeamComplete() {
+		return nil, fmt.Errorf("could not build a team for %s", username)
+	}
+	return bot, nil
+}
+
+func (um *UserManager) isBot(username string) bool {
+	user, exists := um.Users[username]
+	return exists && user.Bot != nil
+}
+
+// playBots lets bots make any choice the battle is waiting on. Their choices
+// go through the same commands a human's do.
+func (um *UserManager) playBots() {
+	for um.BattleStarted {
+		cmd, ok := um.nextBotCommand()
+		if !ok {
+			return
+		}
+		um.handleCommand(cmd)
+	}
+}
+
+// nextBotCommand returns the next choice a bot has to make, if any.
+func (um *UserManager) nextBotCommand() (Command, bool) {
+	for _, name := range um.getPlayerNames() {
+		user := um.Users[name]
+		if user.Bot == nil {
+			continue
+		}
+		switch position := user.choosing(); {
+		case user.AwaitingSwitch:
+			return Command{Username: name, Name: "switch", Arg: strconv.Itoa(um.chooseReplacement(user))}, true
+		case position >= 0 && !um.awaitingReplacement():
+			return um.chooseAction(battler{user: user, position: position}), true
+		}
+	}
+	return Command{}, false
+}
+
+// chooseAction picks the action of one of a bot's positions for the round. In
+// doubles the move is followed by its target.
+func (um *UserManager) chooseAction(b battler) Command {
+	user, attacker := b.user, b.pokemon()
+	target := um.chooseTarget(b)
+	defender := target.pokemon()
+	withTarget := func(move int) Command {
+		arg := strconv.Itoa(move)
+		if len(target.user.Active) > 1 {
+			arg = fmt.Sprintf("%d %d", move, target.position+1)
+		}
+		return Command{Username: user.Username, Name: "move", Arg: arg}
+	}
+
+	usable := usableSlots(attacker)
+	if len(usable) == 0 {
+		// Any move will do; the Pokemon has to Struggle
+		return withTarget(1)
+	}
+
+	pick := usable[um.rng.Intn(len(usable))]
+	switch user.Bot.Difficulty {
+	case DifficultyGreedy:
+		if best, damage := bestMove(attacker, defender); damage > 0 {
+			pick = best
+		}
+	case DifficultyLookahead:
+		if switchTo := lookaheadSwitch(user, attacker, defender); switchTo > 0 {
+			return Command{Username: user.Username, Name: "switch", Arg: strconv.Itoa(switchTo)}
+		}
+		pick = lookaheadMove(attacker, defender)
+	}
+	return withTarget(pick)
+}
+
+// chooseTarget picks the opponent's Pokemon a bot attacks: any of them at
+// random, or otherwise the one it can take the largest share of HP from.
+func (um *UserManager) chooseTarget(b battler) battler {
+	var targets []battler
+	for _, target := range um.getOpponent(b.user.Username).battlers() {
+		if target.alive() {
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == 1 {
+		return targets[0]
+	}
+	if b.user.Bot.Difficulty == DifficultyRandom {
+		return targets[um.rng.Intn(len(targets))]
+	}
+
+	best := targets[0]
+	for _, target := range targets[1:] {
+		if fraction(bestDamage(b.pokemon(), target.pokemon()), target.pokemon()) > fraction(bestDamage(b.pokemon(), best.pokemon()), best.pokemon()) {
+			best = target
+		}
+	}
+	return best
+}
+
+// chooseReplacement picks the 1-based team position of the Pokemon a bot
+// sends in after a knockout.
+func (um *UserManager) chooseReplacement(user *User) int {
+	var available []int
+	for i, pokemon := range user.PokemonData {
+		if user.switchable(pokemon) {
+			available = append(available, i+1)
+		}
+	}
+	if user.Bot.Difficulty == DifficultyRandom {
+		return available[um.rng.Intn(len(available))]
+	}
+
+	// Face the opponent's first Pokemon still standing
+	var opponent *PokemonData
+	for _, b := range um.getOpponent(user.Username).battlers() {
+		if b.alive() {
+			opponent = b.pokemon()
+			break
+		}
+	}
+	best := available[0]
+	for _, index := range available[1:] {
+		if matchup(user.PokemonData[index-1], opponent) > matchup(user.PokemonData[best-1], opponent) {
+			best = index
+		}
+	}
+	return best
+}
+
+// lookaheadSwitch returns the team position to switch to when a benched
+// Pokemon faces the opponent better than the active one does after paying
+// for the switch, or 0 to stay in.
+func lookaheadSwitch(user *User, active, opponent *PokemonData) int {
+	stay := matchup(active, opponent)
+	best, bestScore := 0, stay
+	for i, pokemon := range user.PokemonData {
+		if !user.switchable(pokemon) {
+			continue
+		}
+		// The incoming Pokemon takes a hit without hitting back this round
+		taken := fraction(bestDamage(opponent, pokemon), pokemon)
+		score := matchup(pokemon, opponent) - taken - switchPenalty
+		if score > bestScore {
+			best, bestScore = i+1, score
+		}
+	}
+	return best
+}
+
+// lookaheadMove scores each usable move by the share of the target's HP it is
+// expected to take this round, less the share the attacker expects to lose,
+// and returns the 1-based position of the best one.
+func lookaheadMove(attacker, defender *PokemonData) int {
+	taken := fraction(bestDamage(defender, attacker), attacker)
+	movesFirst := effectiveSpeed(attacker) > effectiveSpeed(defender)
+
+	var best int
+	var bestScore float64
+	for _, index := range usableSlots(attacker) {
+		move := attacker.Moveset[index-1].Move
+		dealt := fraction(expectedDamage(attacker, defender, move), defender)
+		hit := taken
+
+		priority := movePriority[move.Identifier]
+		first := priority > 0 || (priority == 0 && movesFirst)
+		switch {
+		case first && dealt >= 1:
+			// A knockout before the defender moves avoids its hit
+			hit = 0
+		case !first && taken >= 1:
+			// The attacker is knocked out before it can move
+			dealt = 0
+		default:
+			dealt += effectValue(attacker, defender, move)
+		}
+		if score := dealt - hit; best == 0 || score > bestScore {
+			best, bestScore = index, score
+		}
+	}
+	return best
+}
+
+// matchup scores how well a Pokemon does against an opponent: the share of
+// the opponent's HP it can take in one hit less the share it loses to the
+// opponent's best hit.
+func matchup(pokemon, opponent *PokemonData) float64 {
+	return fraction(bestDamage(pokemon, opponent), opponent) - fraction(bestDamage(opponent, pokemon), pokemon)
+}
+
+// bestMove returns the 1-based position of the usable move expected to do the
+// most damage, and that damage.
+func bestMove(attacker, defender *PokemonData) (int, float64) {
+	best, bestDamage := 0, 0.0
+	for _, index := range usableSlots(attacker) {
+		if damage := expectedDamage(attacker, defender, attacker.Moveset[index-1].Move); damage > bestDamage {
+			best, bestDamage = index, damage
+		}
+	}
+	return best, bestDamage
+}
+
+func bestDamage(attacker, defender *PokemonData) float64 {
+	_, damage := bestMove(attacker, defender)
+	return damage
+}
+
+// expectedDamage is the average damage a move does, taking its accuracy into
+// account.
+func expectedDamage(attacker, defender *PokemonData, move *models.Move) float64 {
+	power, _ := movePower(move)
+	effectiveness := typeEffectiveness(move.TypeName, defender)
+	if power == 0 || effectiveness == 0 {
+		return 0
+	}
+
+	hitChance := 1.0
+	if accuracy, ok := moveAccuracy(move); ok {
+		hitChance = float64(accuracy) * accuracyMultiplier(attacker, defender) / 100
+		if hitChance > 1 {
+			hitChance = 1
+		}
+	}
+	damage := float64(baseDamage(attacker, defender, move, power)) * averageDamageRoll * damageMultiplier(attacker, move, effectiveness)
+	return damage * hitChance
+}
+
+// effectValue gives a move's status or stat changes a rough worth in shares of
+// HP, so a lookahead bot will use them when they beat attacking.
+func effectValue(attacker, defender *PokemonData, move *models.Move) float64 {
+	effect, ok := moveEffect(move)
+	if !ok {
+		return 0
+	}
+	chance := 1.0
+	if effect.Chance > 0 {
+		chance = float64(effect.Chance) / 100
+	}
+
+	var value float64
+	if effect.Status != "" && defender.Status == "" && !statusImmune(defender, effect.Status) {
+		value += 0.25
+	}
+	for _, change := range effect.StatChanges {
+		target := defender
+		if change.Self {
+			target = attacker
+		}
+		stage := target.StatStages[change.Stat]
+		if clampStage(stage+change.Stages) == stage {
+			continue
+		}
+		// Raising your own stats or lowering the opponent's is worth more
+		// while the attacker is healthy
+		worth := 0.05 * float64(change.Stages) * float64(attacker.CurrentHP) / float64(attacker.maxHP())
+		if !change.Self {
+			worth = -worth
+		}
+		value += worth
+	}
+	return value * chance
+}
+
+// usableSlots returns the 1-based positions of the moves with PP left.
+func usableSlots(pokemon *PokemonData) []int {
+	var slots []int
+	for i, slot := range pokemon.Moveset {
+		if slot.PP > 0 {
+			slots = append(slots, i+1)
+		}
+	}
+	return slots
+}
+
+// fraction returns damage as a share of the Pokemon's current HP, capped at a
+// knockout.
+func fraction(damage float64, pokemon *PokemonData) float64 {
+	if pokemon.CurrentHP <= 0 {
+		return 0
+	}
+	share := damage / float64(pokemon.CurrentHP)
+	if share > 1 {
+		return 1
+	}
+	return share
+}
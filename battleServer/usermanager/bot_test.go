@@ -0,0 +1,113 @@
+package usermanager
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+	"github.com/nguyensngoc108/pokemon-game/internal/protocol"
+)
+
+func Test_botBattle(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		first  string
+		second string
+	}{
+		{name: "random against greedy", format: DefaultFormat, first: DifficultyRandom, second: DifficultyGreedy},
+		{name: "greedy against lookahead", format: DefaultFormat, first: DifficultyGreedy, second: DifficultyLookahead},
+		{name: "lookahead against itself", format: DefaultFormat, first: DifficultyLookahead, second: DifficultyLookahead},
+		{name: "one Pokemon each", format: "1v1", first: DifficultyGreedy, second: DifficultyLookahead},
+		{name: "six Pokemon each", format: "full", first: DifficultyRandom, second: DifficultyLookahead},
+		{name: "doubles", format: "doubles", first: DifficultyRandom, second: DifficultyGreedy},
+		{name: "lookahead doubles", format: "doubles", first: DifficultyLookahead, second: DifficultyLookahead},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			um := NewUserManagerWithSeed(1)
+			um.Positions = Formats[tt.format].Positions
+			for _, bot := range []struct{ name, difficulty string }{{"first", tt.first}, {"second", tt.second}} {
+				user, err := NewBotUser(bot.name, bot.difficulty, Formats[tt.format], rng)
+				if err != nil {
+					t.Fatal(err)
+				}
+				um.JoinUser(user)
+			}
+
+			// Bots make every choice themselves, so the whole battle plays
+			// out without any commands
+			um.Run()
+
+			var winner string
+			for _, msg := range um.transcripts["first"] {
+				if msg.Type == protocol.TypeResult {
+					winner = msg.Winner
+				}
+			}
+			if winner != "first" && winner != "second" {
+				t.Errorf("winner = %q, want one of the bots", winner)
+			}
+		})
+	}
+}
+
+func Test_bestMove(t *testing.T) {
+	charizard, err := readPokemonJSONData(DataPath + "/monsters/data/6.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	venusaur, err := readPokemonJSONData(DataPath + "/monsters/data/3.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blastoise, err := readPokemonJSONData(DataPath + "/monsters/data/9.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		defender *PokemonData
+		want     string
+	}{
+		{name: "super effective fire move", defender: venusaur, want: "flamethrower"},
+		{name: "neutral move over a resisted one", defender: blastoise, want: "earthquake"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			charizard.Moveset, err = buildMoveset(charizard, []string{"ember", "flamethrower", "earthquake", "growl"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.defender.CurrentHP = tt.defender.Monster.HP
+			index, _ := bestMove(charizard, tt.defender)
+			if index == 0 {
+				t.Fatal("bestMove() found no damaging move")
+			}
+			if got := charizard.Moveset[index-1].Move.Identifier; got != tt.want {
+				t.Errorf("bestMove() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_lookaheadMove_statusMove(t *testing.T) {
+	move := func(identifier, typeName string, power interface{}) *MoveSlot {
+		return &MoveSlot{Move: &models.Move{Identifier: identifier, TypeName: typeName, Power: power, Accuracy: 100}, PP: 10}
+	}
+	attacker := &PokemonData{
+		Monster:   &models.Monster{Name: "Attacker", HP: 100, Attack: 50, Speed: 100},
+		CurrentHP: 100,
+		Moveset:   []*MoveSlot{move("tackle", "normal", 5), move("thunder-wave", "electric", "")},
+	}
+	defender := &PokemonData{
+		Monster:   &models.Monster{Name: "Defender", HP: 100, Defense: 200, Speed: 50},
+		CurrentHP: 100,
+	}
+	// A weak attack is worth less than paralyzing the defender
+	if got := lookaheadMove(attacker, defender); got != 2 {
+		t.Errorf("lookaheadMove() = %d, want 2", got)
+	}
+}
@@ -0,0 +1,188 @@
+package usermanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+	"github.com/nguyensngoc108/pokemon-game/internal/playerfile"
+	"github.com/nguyensngoc108/pokemon-game/internal/protocol"
+)
+
+// CaughtPokemon is a Pokemon a trainer caught in the pokeCat world, as
+// pokeCatserver saves it in players.json. Unlike a Pokemon built from its
+// species name, it is an individual with its own level, IVs, EVs and nature.
+type CaughtPokemon struct {
+	Name string `json:"name"`
+	// ElementalMultiplier is how much moves of the Pokemon's own types
+	// gain on top of their base damage: 0.5 is the usual STAB bonus, and
+	// pokeCat rolls 0.5 to 1 for each Pokemon caught.
+	ElementalMultiplier float64 `json:"elemental_multiplier"`
+	models.Instance
+}
+
+// pokeCatPlayer is the part of a pokeCatserver player battleServer reads.
+type pokeCatPlayer struct {
+	Username string           `json:"username"`
+	Pokemons []*CaughtPokemon `json:"pokemons"`
+}
+
+// LoadCollection reads the Pokemon a trainer has caught from pokeCatserver's
+// players.json. Trainers battle with their collection by logging in with
+// their pokeCat username.
+func LoadCollection(path, username string) ([]*CaughtPokemon, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var players []pokeCatPlayer
+	if err := json.Unmarshal(data, &players); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, player := range players {
+		if player.Username == username {
+			return player.Pokemons, nil
+		}
+	}
+	return nil, fmt.Errorf("%s has not caught any Pokemon in pokeCat", username)
+}
+
+// loadCaught loads a caught Pokemon for battle with the given moves, or the
+// moves it knows if none are given. Its species gives its types and base
+// stats; its level, IVs, EVs and nature are its own.
+func loadCaught(caught *CaughtPokemon, moves []string) (*PokemonData, error) {
+	if len(moves) == 0 {
+		moves = caught.Moves
+	}
+	pokemon, err := loadPokemon(protocol.TeamMember{Name: caught.Name, Moves: moves, Level: caught.Level})
+	if err != nil {
+		return nil, err
+	}
+	pokemon.applyCaught(caught)
+	return pokemon, nil
+}
+
+// loadCaughtMember loads the team member picked from a collection by number.
+func loadCaughtMember(collection []*CaughtPokemon, member protocol.TeamMember) (*PokemonData, error) {
+	if member.Caught > len(collection) {
+		return nil, fmt.Errorf("your collection has no Pokemon %d", member.Caught)
+	}
+	return loadCaught(collection[member.Caught-1], member.Moves)
+}
+
+// applyCaught makes a Pokemon the individual that was caught. It takes a
+// copy, so what the Pokemon earns in battle doesn't change the collection it
+// came from until it is saved.
+func (p *PokemonData) applyCaught(caught *CaughtPokemon) {
+	c := *caught
+	if c.SpeciesID == 0 {
+		c.SpeciesID = p.Monster.NationalID
+	}
+	p.Caught = &c
+	p.Instance = &c.Instance
+}
+
+// SaveCollection writes caught Pokemon that grew in a battle back to
+// pokeCatserver's players.json, matching them by ID. Only the fields battles
+// change are replaced, so everything else pokeCat saves is kept. pokeCat
+// rewrites the file too, so it is changed under playerfile's lock.
+func SaveCollection(path, username string, caught []*CaughtPokemon) error {
+	return playerfile.Update(path, func(data []byte) ([]byte, error) {
+		if data == nil {
+			return nil, fmt.Errorf("%s: %w", path, os.ErrNotExist)
+		}
+		var players []map[string]json.RawMessage
+		if err := json.Unmarshal(data, &players); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		for _, player := range players {
+			var name string
+			json.Unmarshal(player[playerField(player, "username")], &name)
+			if name != username {
+				continue
+			}
+			key := playerField(player, "pokemons")
+			if player[key] == nil {
+				continue
+			}
+			var pokemons []map[string]json.RawMessage
+			if err := json.Unmarshal(player[key], &pokemons); err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+			for _, pokemon := range pokemons {
+				if err := updateCaught(pokemon, caught); err != nil {
+					return nil, err
+				}
+			}
+			var err error
+			if player[key], err = json.Marshal(pokemons); err != nil {
+				return nil, err
+			}
+		}
+		return json.MarshalIndent(players, "", "  ")
+	})
+}
+
+// playerField finds the key of a field in a players.json object. pokeCat
+// has saved players both with and without json tags, so the case of the
+// keys varies.
+func playerField(object map[string]json.RawMessage, name string) string {
+	for key := range object {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+	return name
+}
+
+// updateCaught replaces the individual data and species name of a saved
+// Pokemon with those of the caught Pokemon with the same ID, if there is one.
+func updateCaught(saved map[string]json.RawMessage, caught []*CaughtPokemon) error {
+	var id string
+	json.Unmarshal(saved["id"], &id)
+	if id == "" {
+		return nil
+	}
+	for _, c := range caught {
+		if c.ID != id {
+			continue
+		}
+		data, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+		for key, value := range fields {
+			saved[key] = value
+		}
+	}
+	return nil
+}
+
+// collectionMessage lists a trainer's caught Pokemon by the number used to
+// pick them for a team.
+func collectionMessage(collection []*CaughtPokemon) string {
+	if len(collection) == 0 {
+		return "You haven't caught any Pokemon yet.\n"
+	}
+	var lines []string
+	for i, caught := range collection {
+		line := fmt.Sprintf("  %d. %s level %d", i+1, caught.Name, caught.Level)
+		if caught.Nature != "" {
+			line += fmt.Sprintf(", %s nature", caught.Nature)
+		}
+		if caught.EVs.Total() > 0 {
+			line += fmt.Sprintf(", %d EVs", caught.EVs.Total())
+		}
+		if caught.ElementalMultiplier > 0 {
+			line += fmt.Sprintf(", elemental x%.2f", 1+caught.ElementalMultiplier)
+		}
+		lines = append(lines, line)
+	}
+	return fmt.Sprintf("Your pokeCat collection:\n%s\nPick them for your team as #<n>, e.g. #2:thunderbolt.\n", strings.Join(lines, "\n"))
+}
@@ -0,0 +1,173 @@
+package usermanager
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+	"github.com/nguyensngoc108/pokemon-game/internal/protocol"
+)
+
+const testPlayers = `[
+    {
+        "username": "ash",
+        "password": "1",
+        "pokemons": [
+            {"name": "Pikachu", "hp": 35, "elemental_multiplier": 0.9, "id": "p1", "level": 30,
+             "ivs": {"hp": 31, "attack": 31, "defense": 31, "sp_atk": 31, "sp_def": 31, "speed": 31},
+             "evs": {"speed": 100}, "nature": "timid", "moves": ["thunderbolt", "quick-attack"]},
+            {"name": "Bulbasaur", "elemental_multiplier": 0.6, "id": "b1", "level": 12},
+            {"name": "Squirtle", "level": 8}
+        ]
+    },
+    {
+        "username": "gary",
+        "password": "2",
+        "pokemons": []
+    }
+]`
+
+func writeTestPlayers(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "players.json")
+	if err := os.WriteFile(path, []byte(testPlayers), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLobby_UpdateTeam_caught(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		team     []protocol.TeamMember
+		wantErr  string
+	}{
+		{name: "whole team caught", username: "ash", team: []protocol.TeamMember{{Caught: 1}, {Caught: 2}, {Caught: 3}}},
+		{name: "caught and species", username: "ash", team: []protocol.TeamMember{{Caught: 1, Moves: []string{"thunderbolt"}}, {Name: "Charmander"}, {Caught: 3}}},
+		{name: "number past the collection", username: "ash", team: []protocol.TeamMember{{Caught: 1}, {Caught: 2}, {Caught: 4}}, wantErr: "no Pokemon 4"},
+		{name: "empty collection", username: "gary", team: []protocol.TeamMember{{Caught: 1}, {Name: "Eevee"}, {Name: "Squirtle"}}, wantErr: "no Pokemon 1"},
+		{name: "not a pokeCat trainer", username: "brock", team: []protocol.TeamMember{{Caught: 1}, {Name: "Eevee"}, {Name: "Squirtle"}}, wantErr: "has not caught any Pokemon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lobby := NewLobby()
+			lobby.Collections = writeTestPlayers(t)
+			user, err := lobby.AddUser(tt.username, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = lobby.UpdateTeam(tt.username, tt.team)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("UpdateTeam() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateTeam() error = %v", err)
+			}
+
+			// The caught Pikachu's stats come from its species and its own
+			// level, IVs, EVs and nature
+			pikachu := user.PokemonData[0]
+			if stats := pikachu.stats(); pikachu.Level != 30 || stats.HP != 70 || stats.Attack != 42 || stats.Speed != 82 {
+				t.Errorf("Pikachu is level %d with HP %d, Attack %d and Speed %d, want 30, 70, 42 and 82", pikachu.Level, stats.HP, stats.Attack, stats.Speed)
+			}
+			if len(tt.team[0].Moves) == 0 && (len(pikachu.Moveset) != 2 || pikachu.Moveset[0].Move.Identifier != "thunderbolt") {
+				t.Errorf("Pikachu's moveset has %d moves, want the 2 it knows", len(pikachu.Moveset))
+			}
+			if got := sameTypeAttackBonus("electric", pikachu); got != 1.9 {
+				t.Errorf("Pikachu's STAB = %v, want 1.9", got)
+			}
+		})
+	}
+}
+
+func TestVerifyReplay_caught(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	var log bytes.Buffer
+	um := NewUserManagerWithSeed(5)
+	um.ReplayLog = &log
+	for _, name := range []string{"first", "second"} {
+		bot, err := NewBotUser(name, DifficultyGreedy, Formats[DefaultFormat], rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		um.JoinUser(bot)
+	}
+	// The replay has to carry the caught Pokemon's IVs, EVs and nature to
+	// play out the same way
+	um.Users["first"].PokemonData[0].applyCaught(&CaughtPokemon{
+		Name:                um.Users["first"].Pokemons[0],
+		ElementalMultiplier: 1,
+		Instance:            models.Instance{Level: 50, EVs: models.Stats{Attack: 252, SpAtk: 252}, Nature: "hardy"},
+	})
+	um.Run()
+
+	events, err := ReadReplay(&log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyReplay(events); err != nil {
+		t.Errorf("VerifyReplay() error = %v", err)
+	}
+}
+
+func TestSaveCollection(t *testing.T) {
+	path := writeTestPlayers(t)
+	collection, err := LoadCollection(path, "ash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pikachu, err := loadCaught(collection[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bulbasaur, err := loadCaught(collection[1], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pikachu knocks out a level 50 Bulbasaur, worth 1 Special Attack EV,
+	// and the caught Bulbasaur has earned enough to evolve when the battle
+	// ends
+	opponent, err := loadPokemon(protocol.TeamMember{Name: "Bulbasaur"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	earnRewards(pikachu, opponent)
+	bulbasaur.ExperienceEarned = models.ExperienceForLevel(16) - models.ExperienceForLevel(12)
+	um := NewUserManagerWithSeed(1)
+	um.Users["ash"] = &User{Username: "ash", PokemonData: []*PokemonData{pikachu, bulbasaur}}
+	um.awardRewards()
+	if want := (models.Stats{SpAtk: 1, Speed: 100}); pikachu.Instance.EVs != want {
+		t.Errorf("Pikachu's EVs = %+v, want %+v", pikachu.Instance.EVs, want)
+	}
+	if want := models.ExperienceForLevel(30) + models.ExperienceYield(opponent.Monster, 50); pikachu.Instance.Experience != want {
+		t.Errorf("Pikachu's experience = %d, want %d", pikachu.Instance.Experience, want)
+	}
+
+	if err := SaveCollection(path, "ash", []*CaughtPokemon{pikachu.Caught, bulbasaur.Caught}); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := LoadCollection(path, "ash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 3 || saved[0].EVs != pikachu.Instance.EVs || saved[0].Experience != pikachu.Instance.Experience || saved[0].ElementalMultiplier != 0.9 {
+		t.Errorf("saved Pikachu = %+v, want its new EVs and experience", saved[0])
+	}
+	if ivysaur := saved[1]; ivysaur.Name != "Ivysaur" || ivysaur.SpeciesID != 2 || ivysaur.Level != 16 {
+		t.Errorf("saved Bulbasaur = %+v, want a level 16 Ivysaur", ivysaur)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"password": "1"`) {
+		t.Error("SaveCollection() dropped the players' passwords")
+	}
+}
@@ -0,0 +1,97 @@
+package usermanager
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/protocol"
+)
+
+// DefaultReconnectGrace is how long a disconnected user's seat in a battle is
+// held before they forfeit.
+const DefaultReconnectGrace = time.Minute
+
+// sendToUser records a message in the user's transcript of the battle and
+// sends it unless the user is disconnected or has no connection, as bots and
+// the users of a verified replay don't. The transcript is replayed when they
+// reconnect.
+func (um *UserManager) sendToUser(user *User, msg protocol.Message) {
+	um.transcripts[user.Username] = append(um.transcripts[user.Username], msg)
+	if um.disconnected[user.Username] || user.Conn == nil {
+		return
+	}
+	user.send(msg)
+}
+
+// userDisconnected holds the user's seat for the reconnect grace period and
+// lets their opponent know.
+func (um *UserManager) userDisconnected(username string) {
+	user, exists := um.Users[username]
+	if !exists || um.disconnected[username] {
+		return
+	}
+	um.disconnected[username] = true
+
+	grace := um.ReconnectGrace
+	if grace <= 0 {
+		grace = DefaultReconnectGrace
+	}
+	um.forfeitTimers[username] = time.AfterFunc(grace, func() {
+		um.Submit(Command{Username: username, Name: commandForfeit, system: true})
+	})
+
+	if opponent := um.getOpponent(username); opponent != nil {
+		um.sendMessageToUser(opponent, fmt.Sprintf("%s disconnected. Waiting up to %s for them to return.\n", user.Username, grace))
+	}
+}
+
+// userReconnected resumes the battle for a user on their new connection,
+// replaying everything that was sent to them during the battle.
+func (um *UserManager) userReconnected(username string, conn net.Conn) {
+	user, exists := um.Users[username]
+	if !exists {
+		return
+	}
+	if timer, ok := um.forfeitTimers[username]; ok {
+		timer.Stop()
+		delete(um.forfeitTimers, username)
+	}
+	delete(um.disconnected, username)
+	user.Conn = conn
+
+	user.send(protocol.StateMessage("Reconnected. Replaying your battle so far...\n"))
+	for _, msg := range um.transcripts[username] {
+		user.send(msg)
+	}
+	if opponent := um.getOpponent(username); opponent != nil {
+		um.sendMessageToUser(opponent, fmt.Sprintf("%s reconnected.\n", user.Username))
+	}
+}
+
+// forfeitIfDisconnected ends the battle in the opponent's favour when the user
+// hasn't come back within the grace period.
+func (um *UserManager) forfeitIfDisconnected(username string) {
+	if !um.disconnected[username] {
+		return
+	}
+	delete(um.forfeitTimers, username)
+	um.forfeit(username)
+}
+
+// forfeit ends the battle in the opponent's favour.
+func (um *UserManager) forfeit(username string) {
+	um.record(ReplayEvent{Type: ReplayForfeit, Round: um.Round, Username: username})
+	if opponent := um.getOpponent(username); opponent != nil {
+		um.sendMessageToUser(opponent, fmt.Sprintf("%s did not return in time and forfeits.\n", username))
+		um.announceWinner(opponent.Username)
+	}
+}
+
+// stopForfeitTimers cancels any pending forfeits once the battle is over.
+func (um *UserManager) stopForfeitTimers() {
+	for username, timer := range um.forfeitTimers {
+		timer.Stop()
+		delete(um.forfeitTimers, username)
+	}
+}
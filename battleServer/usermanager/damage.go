@@ -0,0 +1,131 @@
+package usermanager
+
+import (
+	"math/rand"
+	"strconv"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+const (
+	defaultLevel       = 50
+	criticalHitChance  = 16 // one in criticalHitChance hits is critical
+	criticalMultiplier = 1.5
+	minDamageRoll      = 85  // damage is scaled by a random 85..100 percent
+	burnMultiplier     = 0.5 // burned Pokemon deal half damage with physical moves
+)
+
+// physicalTypes lists the move types that use Attack and Defense. The move
+// data has no damage class, so as in the older games the split is by type and
+// everything else uses Special Attack and Special Defense.
+var physicalTypes = map[string]bool{
+	"normal":   true,
+	"fighting": true,
+	"flying":   true,
+	"poison":   true,
+	"ground":   true,
+	"rock":     true,
+	"bug":      true,
+	"ghost":    true,
+	"steel":    true,
+}
+
+// DamageResult describes the outcome of a single attack.
+type DamageResult struct {
+	Damage        int
+	Missed        bool
+	Critical      bool
+	Effectiveness float64
+}
+
+// calculateDamage rolls accuracy, critical hit and damage spread for a move
+// using the given random source.
+func calculateDamage(rng *rand.Rand, attacker, defender *PokemonData, move *models.Move) DamageResult {
+	result := DamageResult{Effectiveness: typeEffectiveness(move.TypeName, defender)}
+
+	if accuracy, ok := moveAccuracy(move); ok && rng.Intn(100) >= int(float64(accuracy)*accuracyMultiplier(attacker, defender)) {
+		result.Missed = true
+		return result
+	}
+
+	power, _ := movePower(move)
+	if power == 0 || result.Effectiveness == 0 {
+		return result
+	}
+
+	base := baseDamage(attacker, defender, move, power)
+	modifier := float64(minDamageRoll+rng.Intn(101-minDamageRoll)) / 100
+	if rng.Intn(criticalHitChance) == 0 {
+		result.Critical = true
+		modifier *= criticalMultiplier
+	}
+	modifier *= damageMultiplier(attacker, move, result.Effectiveness)
+
+	result.Damage = int(float64(base) * modifier)
+	if result.Damage < 1 {
+		result.Damage = 1
+	}
+	return result
+}
+
+// baseDamage is the damage a move does before the random roll and any
+// multipliers are applied.
+func baseDamage(attacker, defender *PokemonData, move *models.Move, power int) int {
+	attack, defense := modifiedStat(attacker, StatSpAtk), modifiedStat(defender, StatSpDef)
+	if physicalTypes[move.TypeName] {
+		attack, defense = modifiedStat(attacker, StatAttack), modifiedStat(defender, StatDefense)
+	}
+	if defense < 1 {
+		defense = 1
+	}
+
+	level := pokemonLevel(attacker)
+	return (2*level/5+2)*power*attack/defense/50 + 2
+}
+
+// damageMultiplier combines the multipliers that don't depend on chance: STAB,
+// type effectiveness and burn.
+func damageMultiplier(attacker *PokemonData, move *models.Move, effectiveness float64) float64 {
+	multiplier := sameTypeAttackBonus(move.TypeName, attacker) * effectiveness
+	if attacker.Status == StatusBurn && physicalTypes[move.TypeName] {
+		multiplier *= burnMultiplier
+	}
+	return multiplier
+}
+
+// pokemonLevel returns the battle level of a Pokemon, falling back to
+// defaultLevel for species data that carries no level.
+func pokemonLevel(p *PokemonData) int {
+	if p.Level > 0 {
+		return p.Level
+	}
+	return defaultLevel
+}
+
+// movePower returns the base power of a move. Status moves have an empty power
+// in the move data and report false.
+func movePower(move *models.Move) (int, bool) {
+	return intValue(move.Power)
+}
+
+// moveAccuracy returns the accuracy of a move in percent. Moves that never
+// miss have an empty accuracy in the move data and report false.
+func moveAccuracy(move *models.Move) (int, bool) {
+	return intValue(move.Accuracy)
+}
+
+// intValue reads a loosely typed number from the move data files.
+func intValue(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int(n), true
+	case int:
+		return n, true
+	case string:
+		i, err := strconv.Atoi(n)
+		if err == nil {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,60 @@
+package usermanager
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+func Test_calculateDamage(t *testing.T) {
+	normal := &models.Types{WhenDefending: []models.When{{Multiplier: 0.0, Name: "ghost"}}}
+	attacker := &PokemonData{Monster: &models.Monster{Attack: 100, Defense: 100, SpAtk: 100, SpDef: 100, Types: []string{"normal"}}}
+	defender := &PokemonData{Monster: &models.Monster{Attack: 100, Defense: 100, SpAtk: 100, SpDef: 100, Types: []string{"normal"}}, Types: []*models.Types{normal}}
+
+	// Level 50, power 80, equal stats: base damage is 37, STAB makes it 55
+	// before the random spread and critical hits.
+	tests := []struct {
+		name       string
+		move       *models.Move
+		wantMissed bool
+		minDamage  int
+		maxDamage  int
+	}{
+		{name: "regular hit", move: &models.Move{TypeName: "normal", Power: 80.0, Accuracy: 100.0}, minDamage: 46, maxDamage: 83},
+		{name: "never misses", move: &models.Move{TypeName: "normal", Power: 80.0, Accuracy: ""}, minDamage: 46, maxDamage: 83},
+		{name: "always misses", move: &models.Move{TypeName: "normal", Power: 80.0, Accuracy: 0.0}, wantMissed: true},
+		{name: "status move", move: &models.Move{TypeName: "normal", Power: "", Accuracy: 100.0}},
+		{name: "immune", move: &models.Move{TypeName: "ghost", Power: 80.0, Accuracy: 100.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			for i := 0; i < 100; i++ {
+				got := calculateDamage(rng, attacker, defender, tt.move)
+				if got.Missed != tt.wantMissed {
+					t.Fatalf("calculateDamage() missed = %v, want %v", got.Missed, tt.wantMissed)
+				}
+				if got.Damage < tt.minDamage || got.Damage > tt.maxDamage {
+					t.Fatalf("calculateDamage() damage = %d, want between %d and %d", got.Damage, tt.minDamage, tt.maxDamage)
+				}
+			}
+		})
+	}
+}
+
+func Test_calculateDamageSeeded(t *testing.T) {
+	attacker := &PokemonData{Monster: &models.Monster{SpAtk: 109, Types: []string{"fire"}}, Level: 36}
+	defender := &PokemonData{Monster: &models.Monster{SpDef: 65}}
+	move := &models.Move{TypeName: "fire", Power: 90.0, Accuracy: 70.0}
+
+	first := rand.New(rand.NewSource(42))
+	second := rand.New(rand.NewSource(42))
+	for i := 0; i < 50; i++ {
+		a := calculateDamage(first, attacker, defender, move)
+		b := calculateDamage(second, attacker, defender, move)
+		if a != b {
+			t.Fatalf("roll %d: same seed gave %+v and %+v", i, a, b)
+		}
+	}
+}
@@ -0,0 +1,623 @@
+package usermanager
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/nguyensngoc108/pokemon-game/battleServer/accounts"
+	"github.com/nguyensngoc108/pokemon-game/internal/protocol"
+)
+
+const (
+	// ladderSize is how many players the ladder command lists.
+	ladderSize = 10
+	// historySize is how many recent battles the history command lists.
+	historySize = 10
+)
+
+// Lobby holds every connected user and pairs them up into battles. Each
+// battle runs as its own UserManager session on its own goroutine.
+type Lobby struct {
+	// ReconnectGrace is how long a user who drops out of a battle has to
+	// reconnect before forfeiting.
+	ReconnectGrace time.Duration
+	// ReplayDir is the directory each battle's replay log is written to.
+	// No logs are kept when it is empty.
+	ReplayDir string
+	// Ruleset is the format every team in the lobby must follow.
+	Ruleset *Ruleset
+	// Collections is the path of pokeCatserver's players.json, which teams
+	// may pick caught Pokemon from. Teams are built from species names alone
+	// when it is empty.
+	Collections string
+	// Accounts holds the registered trainers and their ratings. Without it
+	// anyone may log in as any free username and battles aren't rated.
+	Accounts *accounts.Store
+
+	mu           sync.Mutex
+	users        map[string]*User
+	conns        map[string]net.Conn
+	disconnected map[string]bool
+	sessions     map[string]*UserManager
+	// battles describes each running battle by its users, e.g. "ash vs gary".
+	battles map[*UserManager]string
+	// watching holds the battle each spectator is watching.
+	watching   map[string]*UserManager
+	challenges map[string]string
+	queue      []string
+	// rng picks the teams of bots.
+	rng *rand.Rand
+}
+
+func NewLobby() *Lobby {
+	return &Lobby{
+		ReconnectGrace: DefaultReconnectGrace,
+		Ruleset:        Formats[DefaultFormat],
+		users:          make(map[string]*User),
+		conns:          make(map[string]net.Conn),
+		disconnected:   make(map[string]bool),
+		sessions:       make(map[string]*UserManager),
+		battles:        make(map[*UserManager]string),
+		watching:       make(map[string]*UserManager),
+		challenges:     make(map[string]string),
+		rng:            rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Authenticate checks a user's password before they are added to the lobby,
+// or creates their account when register is set. Without accounts every
+// login is let through.
+func (l *Lobby) Authenticate(username, password string, register bool) error {
+	if l.Accounts == nil {
+		if register {
+			return fmt.Errorf("this server doesn't keep accounts, log in with any free username")
+		}
+		return nil
+	}
+	if register {
+		return l.Accounts.Register(username, password)
+	}
+	return l.Accounts.Login(username, password)
+}
+
+// AddUser registers a newly connected user. Usernames must be unique among
+// connected users, except that a user who dropped out of a battle can log in
+// again to resume it.
+func (l *Lobby) AddUser(username string, conn net.Conn) (*User, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if username == "" {
+		return nil, fmt.Errorf("username cannot be empty")
+	}
+	if user, exists := l.users[username]; exists {
+		session, inBattle := l.sessions[username]
+		if !l.disconnected[username] || !inBattle {
+			return nil, fmt.Errorf("username %s is already taken", username)
+		}
+		delete(l.disconnected, username)
+		l.conns[username] = conn
+		session.SubmitReconnect(username, conn)
+		return user, nil
+	}
+	user := &User{
+		Username: username,
+		Pokemons: make([]string, l.Ruleset.TeamSize),
+		Conn:     conn,
+	}
+	l.users[username] = user
+	l.conns[username] = conn
+	welcome := fmt.Sprintf("Welcome, %s! Battles here follow %s.", username, l.Ruleset.Describe())
+	if account, ok := l.account(username); ok {
+		welcome += fmt.Sprintf(" Your rating is %d.", account.Rating)
+	}
+	l.sendMessage(user, welcome+"\n")
+	return user, nil
+}
+
+// Disconnect handles a user's connection going away. Users in a battle keep
+// their seat for the reconnect grace period; anyone else is removed.
+func (l *Lobby) Disconnect(username string, conn net.Conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// The user may already be back on a newer connection
+	if l.conns[username] != conn {
+		return
+	}
+	if session, inBattle := l.sessions[username]; inBattle {
+		l.disconnected[username] = true
+		session.SubmitDisconnect(username)
+		return
+	}
+	l.removeUser(username)
+}
+
+// RemoveUser drops a user from the lobby, the matchmaking queue and any
+// pending challenges.
+func (l *Lobby) RemoveUser(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.removeUser(username)
+}
+
+func (l *Lobby) removeUser(username string) {
+	delete(l.users, username)
+	delete(l.conns, username)
+	delete(l.disconnected, username)
+	delete(l.challenges, username)
+	l.stopWatching(username)
+	for challenger, challenged := range l.challenges {
+		if challenged == username {
+			delete(l.challenges, challenger)
+		}
+	}
+	l.leaveQueue(username)
+}
+
+// UpdateTeam loads the user's team. Teams can't be changed during a battle.
+func (l *Lobby) UpdateTeam(username string, team []protocol.TeamMember) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	user, exists := l.users[username]
+	if !exists {
+		return fmt.Errorf("user %s not found", username)
+	}
+	if _, inBattle := l.sessions[username]; inBattle {
+		return fmt.Errorf("%s is in a battle", username)
+	}
+	if len(team) != l.Ruleset.TeamSize {
+		return &TeamError{Format: l.Ruleset.Format, Problems: []string{fmt.Sprintf("a team must have exactly %d Pokemon", l.Ruleset.TeamSize)}}
+	}
+	// Load and check the whole team before replacing the old one, so a bad
+	// Pokemon doesn't leave a half-updated team behind
+	draft := &User{Username: username, Pokemons: make([]string, len(team))}
+	var problems []string
+	var collection []*CaughtPokemon
+	for i, member := range team {
+		var pokemon *PokemonData
+		var err error
+		if member.Caught > 0 {
+			// Caught Pokemon battle at the level they are in pokeCat
+			if collection == nil {
+				collection, err = l.collection(username)
+			}
+			if err == nil {
+				pokemon, err = loadCaughtMember(collection, member)
+			}
+		} else {
+			if member.Level == 0 {
+				member.Level = l.Ruleset.DefaultLevel()
+			}
+			pokemon, err = loadPokemon(member)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Pokemon %d: %v", i+1, err))
+			continue
+		}
+		draft.PokemonData = append(draft.PokemonData, pokemon)
+		draft.UpdatePokemons(pokemon.Monster.Name, i+1)
+	}
+	if len(problems) > 0 {
+		return &TeamError{Format: l.Ruleset.Format, Problems: problems}
+	}
+	if err := l.Ruleset.Validate(draft.PokemonData); err != nil {
+		return err
+	}
+	user.Pokemons, user.PokemonData = draft.Pokemons, draft.PokemonData
+
+	l.sendMessage(user, "Your team is ready. Commands: 'rules', 'list', 'queue', 'challenge <name>', 'accept <name>', 'bot [random|greedy|lookahead]', 'battles', 'watch <name>', 'unwatch', 'collection', 'ladder', 'history', 'leave'.\n")
+	return nil
+}
+
+// HandleCommand runs a lobby command, or forwards it to the user's battle when
+// they are in one.
+func (l *Lobby) HandleCommand(username, name, arg string) {
+	l.mu.Lock()
+	if session, inBattle := l.sessions[username]; inBattle {
+		l.mu.Unlock()
+		session.Submit(Command{Username: username, Name: name, Arg: arg})
+		return
+	}
+	defer l.mu.Unlock()
+
+	user, exists := l.users[username]
+	if !exists {
+		return
+	}
+	if _, watching := l.watching[username]; watching && (name == "move" || name == "switch" || name == "quit") {
+		l.sendMessage(user, "You are only watching this battle. Type 'unwatch' to stop.\n")
+		return
+	}
+
+	switch name {
+	case "list":
+		l.sendMessage(user, l.waitingPlayersMessage(username))
+	case "queue":
+		if !l.teamReady(user) {
+			return
+		}
+		l.leaveQueue(username)
+		l.queue = append(l.queue, username)
+		l.sendMessage(user, "You joined the matchmaking queue.\n")
+		l.matchQueue()
+	case "challenge":
+		if !l.teamReady(user) {
+			return
+		}
+		opponent, ok := l.availableUser(arg)
+		if !ok || arg == username {
+			l.sendMessage(user, fmt.Sprintf("%s is not available to battle.\n", arg))
+			return
+		}
+		l.challenges[username] = arg
+		l.sendMessage(user, fmt.Sprintf("You challenged %s.\n", arg))
+		l.sendMessage(opponent, fmt.Sprintf("%s challenged you to a battle. Type 'accept %s' to accept.\n", username, username))
+	case "accept":
+		if !l.teamReady(user) {
+			return
+		}
+		challenger, ok := l.availableUser(arg)
+		if !ok || l.challenges[arg] != username {
+			l.sendMessage(user, fmt.Sprintf("There is no challenge from %s.\n", arg))
+			return
+		}
+		l.startBattle(challenger, user)
+	case "bot":
+		if !l.teamReady(user) {
+			return
+		}
+		l.startBotBattle(user, arg)
+	case "rules":
+		l.sendMessage(user, fmt.Sprintf("Team rules for %s.\n", l.Ruleset.Describe()))
+	case "battles":
+		l.sendMessage(user, l.battlesMessage())
+	case "watch":
+		session, inBattle := l.sessions[arg]
+		if !inBattle {
+			l.sendMessage(user, fmt.Sprintf("%s is not in a battle.\n", arg))
+			return
+		}
+		l.stopWatching(username)
+		l.watching[username] = session
+		session.SubmitWatch(username, l.conns[username])
+	case "unwatch":
+		if l.stopWatching(username) {
+			l.sendMessage(user, "You stopped watching.\n")
+		}
+	case "collection":
+		collection, err := l.collection(username)
+		if err != nil {
+			l.sendMessage(user, fmt.Sprintf("Could not load your collection: %v\n", err))
+			return
+		}
+		l.sendMessage(user, collectionMessage(collection))
+	case "ladder":
+		l.sendMessage(user, l.ladderMessage())
+	case "history":
+		l.sendMessage(user, l.historyMessage(username))
+	case "leave":
+		l.leaveQueue(username)
+		delete(l.challenges, username)
+		l.sendMessage(user, "You left the matchmaking queue.\n")
+	default:
+		l.sendMessage(user, fmt.Sprintf("Unknown lobby command: %s\n", name))
+	}
+}
+
+// matchQueue starts battles for queued users two at a time.
+func (l *Lobby) matchQueue() {
+	for len(l.queue) >= 2 {
+		first, second := l.users[l.queue[0]], l.users[l.queue[1]]
+		l.queue = l.queue[2:]
+		l.startBattle(first, second)
+	}
+}
+
+// startBattle pairs two users into a new battle session running on its own
+// goroutine.
+func (l *Lobby) startBattle(first, second *User) {
+	session := NewUserManager()
+	session.ReconnectGrace = l.ReconnectGrace
+	session.Positions = l.Ruleset.Positions
+	session.JoinUser(first)
+	session.JoinUser(second)
+	session.OnFinish = l.finishBattle
+	if l.ReplayDir != "" {
+		replayLog, err := createReplayLog(l.ReplayDir, first.Username, second.Username)
+		if err != nil {
+			fmt.Printf("Error creating replay log: %v\n", err)
+		} else {
+			session.ReplayLog = replayLog
+		}
+	}
+	for _, user := range []*User{first, second} {
+		// Bots aren't lobby users, and their names may clash with one
+		if user.Bot != nil {
+			continue
+		}
+		l.leaveQueue(user.Username)
+		delete(l.challenges, user.Username)
+		l.stopWatching(user.Username)
+		l.sessions[user.Username] = session
+	}
+	l.battles[session] = fmt.Sprintf("%s vs %s", first.Username, second.Username)
+
+	go session.Run()
+}
+
+// startBotBattle starts a battle between the user and a bot of the given
+// difficulty, greedy by default, with a random team the size of the user's.
+func (l *Lobby) startBotBattle(user *User, difficulty string) {
+	if difficulty == "" {
+		difficulty = DifficultyGreedy
+	}
+	if !ValidDifficulty(difficulty) {
+		l.sendMessage(user, fmt.Sprintf("Unknown bot difficulty %s. Choose random, greedy or lookahead.\n", difficulty))
+		return
+	}
+
+	botName := "CPU"
+	if botName == user.Username {
+		botName = "CPU 2"
+	}
+	bot, err := NewBotUser(botName, difficulty, l.Ruleset, l.rng)
+	if err != nil {
+		l.sendMessage(user, fmt.Sprintf("Could not start a bot battle: %v\n", err))
+		return
+	}
+	l.sendMessage(user, fmt.Sprintf("You are battling a %s bot.\n", difficulty))
+	l.startBattle(user, bot)
+}
+
+// finishBattle returns the users of a finished battle to the lobby, dropping
+// any who never came back after disconnecting.
+func (l *Lobby) finishBattle(session *UserManager) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if replayLog, ok := session.ReplayLog.(io.Closer); ok {
+		replayLog.Close()
+	}
+	l.rateBattle(session)
+	l.saveCollections(session)
+	delete(l.battles, session)
+	for username, s := range l.watching {
+		if s != session {
+			continue
+		}
+		delete(l.watching, username)
+		if user, exists := l.users[username]; exists {
+			l.sendMessage(user, "The battle you were watching is over.\n")
+		}
+	}
+	for username, s := range l.sessions {
+		if s != session {
+			continue
+		}
+		delete(l.sessions, username)
+		if l.disconnected[username] {
+			l.removeUser(username)
+			continue
+		}
+		if user, exists := l.users[username]; exists {
+			l.sendMessage(user, "\nYou are back in the lobby.\n")
+		}
+	}
+}
+
+// rateBattle records a finished battle between two account holders and tells
+// them their new ratings. Battles against bots aren't rated.
+func (l *Lobby) rateBattle(session *UserManager) {
+	if l.Accounts == nil || session.Winner == "" {
+		return
+	}
+	winner, loser := session.Users[session.Winner], session.getOpponent(session.Winner)
+	if winner == nil || loser == nil || winner.Bot != nil || loser.Bot != nil {
+		return
+	}
+	won, lost, err := l.Accounts.RecordBattle(winner.Username, loser.Username, time.Now())
+	if err != nil {
+		fmt.Printf("Error rating battle: %v\n", err)
+		return
+	}
+	for _, rated := range []struct {
+		user   *User
+		result accounts.Result
+	}{{winner, won}, {loser, lost}} {
+		if user, exists := l.users[rated.user.Username]; exists {
+			l.sendMessage(user, fmt.Sprintf("Your rating is now %d (%+d).\n", rated.result.Rating, rated.result.Change))
+		}
+	}
+}
+
+// saveCollections writes the caught Pokemon of a finished battle back to
+// pokeCat, keeping the EVs, experience and levels they earned.
+func (l *Lobby) saveCollections(session *UserManager) {
+	if l.Collections == "" {
+		return
+	}
+	for _, user := range session.Users {
+		var caught []*CaughtPokemon
+		for _, pokemon := range user.PokemonData {
+			if pokemon.Caught != nil {
+				caught = append(caught, pokemon.Caught)
+			}
+		}
+		if len(caught) == 0 {
+			continue
+		}
+		if err := SaveCollection(l.Collections, user.Username, caught); err != nil {
+			fmt.Printf("Error saving %s's collection: %v\n", user.Username, err)
+		}
+	}
+}
+
+// createReplayLog creates the file a battle's replay log is written to, named
+// after its start time and users.
+func createReplayLog(dir, first, second string) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s-%s-vs-%s.jsonl", time.Now().Format("20060102-150405.000"), fileSafe(first), fileSafe(second))
+	return os.Create(filepath.Join(dir, name))
+}
+
+// fileSafe replaces the characters of a username that don't belong in a file
+// name.
+func fileSafe(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// availableUser returns a connected user with a full team who isn't battling.
+func (l *Lobby) availableUser(username string) (*User, bool) {
+	user, exists := l.users[username]
+	if !exists || !user.TeamComplete() {
+		return nil, false
+	}
+	if _, inBattle := l.sessions[username]; inBattle {
+		return nil, false
+	}
+	return user, true
+}
+
+func (l *Lobby) teamReady(user *User) bool {
+	if !user.TeamComplete() {
+		l.sendMessage(user, "Choose your team before battling.\n")
+		return false
+	}
+	return true
+}
+
+func (l *Lobby) leaveQueue(username string) {
+	for i, name := range l.queue {
+		if name == username {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// stopWatching stops the user watching a battle, reporting whether they were.
+func (l *Lobby) stopWatching(username string) bool {
+	session, watching := l.watching[username]
+	if !watching {
+		return false
+	}
+	delete(l.watching, username)
+	session.SubmitUnwatch(username)
+	return true
+}
+
+// battlesMessage lists the battles that can be watched.
+func (l *Lobby) battlesMessage() string {
+	if len(l.battles) == 0 {
+		return "No battles are running.\n"
+	}
+	var battles []string
+	for _, battle := range l.battles {
+		battles = append(battles, "  "+battle)
+	}
+	sort.Strings(battles)
+	return fmt.Sprintf("Running battles:\n%s\nType 'watch <name>' to watch one.\n", strings.Join(battles, "\n"))
+}
+
+// waitingPlayersMessage lists the users who are ready to battle.
+func (l *Lobby) waitingPlayersMessage(username string) string {
+	var names []string
+	for name := range l.users {
+		if _, ok := l.availableUser(name); ok && name != username {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "No other players are waiting.\n"
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		line := "  " + name
+		for _, queued := range l.queue {
+			if queued == name {
+				line += " (queued)"
+			}
+		}
+		if l.challenges[name] == username {
+			line += " (challenged you)"
+		}
+		lines = append(lines, line)
+	}
+	return fmt.Sprintf("Waiting players:\n%s\n", strings.Join(lines, "\n"))
+}
+
+// collection loads the Pokemon the user has caught in pokeCat.
+func (l *Lobby) collection(username string) ([]*CaughtPokemon, error) {
+	if l.Collections == "" {
+		return nil, fmt.Errorf("this server isn't linked to a pokeCat world")
+	}
+	return LoadCollection(l.Collections, username)
+}
+
+// account returns the user's account, if the lobby keeps accounts.
+func (l *Lobby) account(username string) (accounts.Account, bool) {
+	if l.Accounts == nil {
+		return accounts.Account{}, false
+	}
+	return l.Accounts.Get(username)
+}
+
+// ladderMessage lists the highest rated players.
+func (l *Lobby) ladderMessage() string {
+	if l.Accounts == nil {
+		return "Battles on this server aren't rated.\n"
+	}
+	ladder := l.Accounts.Ladder(ladderSize)
+	if len(ladder) == 0 {
+		return "Nobody is on the ladder yet.\n"
+	}
+	var lines []string
+	for i, account := range ladder {
+		lines = append(lines, fmt.Sprintf("  %d. %s %d (%d-%d)", i+1, account.Username, account.Rating, account.Wins, account.Losses))
+	}
+	return fmt.Sprintf("Ladder:\n%s\n", strings.Join(lines, "\n"))
+}
+
+// historyMessage shows the user's record and their most recent rated
+// battles, newest first.
+func (l *Lobby) historyMessage(username string) string {
+	account, ok := l.account(username)
+	if !ok {
+		return "Battles on this server aren't rated.\n"
+	}
+	lines := []string{fmt.Sprintf("%s: rating %d, %d wins, %d losses", account.Username, account.Rating, account.Wins, account.Losses)}
+	for i := len(account.History) - 1; i >= 0 && i >= len(account.History)-historySize; i-- {
+		result := account.History[i]
+		outcome := "Lost to"
+		if result.Won {
+			outcome = "Beat"
+		}
+		lines = append(lines, fmt.Sprintf("  %s %s %s, rating %d (%+d)", result.At.Format("2006-01-02 15:04"), outcome, result.Opponent, result.Rating, result.Change))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (l *Lobby) sendMessage(user *User, message string) {
+	user.send(protocol.StateMessage(message))
+}
@@ -0,0 +1,154 @@
+package usermanager
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/protocol"
+)
+
+// joinLobby adds a user with a full team to the lobby.
+func joinLobby(t *testing.T, l *Lobby, username string) *recordConn {
+	t.Helper()
+	conn := &recordConn{}
+	if _, err := l.AddUser(username, conn); err != nil {
+		t.Fatal(err)
+	}
+	team := []protocol.TeamMember{{Name: "Pikachu"}, {Name: "Bulbasaur"}, {Name: "Squirtle"}}
+	if err := l.UpdateTeam(username, team); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// session returns the battle a user is in, if any.
+func (l *Lobby) session(username string) *UserManager {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sessions[username]
+}
+
+func TestLobby_AddUser(t *testing.T) {
+	l := NewLobby()
+	if _, err := l.AddUser("ash", &recordConn{}); err != nil {
+		t.Fatalf("AddUser() error = %v", err)
+	}
+	tests := []struct {
+		name     string
+		username string
+	}{
+		{name: "empty username", username: ""},
+		{name: "taken username", username: "ash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := l.AddUser(tt.username, &recordConn{}); err == nil {
+				t.Errorf("AddUser(%q) succeeded, want an error", tt.username)
+			}
+		})
+	}
+
+	// A username is free again once its user leaves
+	l.RemoveUser("ash")
+	if _, err := l.AddUser("ash", &recordConn{}); err != nil {
+		t.Errorf("AddUser() after RemoveUser() error = %v", err)
+	}
+}
+
+func TestLobby_pairing(t *testing.T) {
+	tests := []struct {
+		name string
+		// commands are run in order as "username command arg"
+		commands   []string
+		wantPaired bool
+		wantText   string
+	}{
+		{name: "queue pairs two users", commands: []string{"ash queue", "gary queue"}, wantPaired: true},
+		{name: "one user waits in the queue", commands: []string{"ash queue"}, wantText: "You joined the matchmaking queue."},
+		{name: "left the queue", commands: []string{"ash queue", "ash leave", "gary queue"}, wantText: "You left the matchmaking queue."},
+		{name: "accepted challenge", commands: []string{"ash challenge gary", "gary accept ash"}, wantPaired: true},
+		{name: "challenge to no one", commands: []string{"ash challenge misty"}, wantText: "misty is not available to battle."},
+		{name: "challenge to themselves", commands: []string{"ash challenge ash"}, wantText: "ash is not available to battle."},
+		{name: "accept without a challenge", commands: []string{"ash accept gary"}, wantText: "There is no challenge from gary."},
+		{name: "unknown command", commands: []string{"ash dance"}, wantText: "Unknown lobby command: dance"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLobby()
+			ash := joinLobby(t, l, "ash")
+			joinLobby(t, l, "gary")
+			for _, command := range tt.commands {
+				parts := append(strings.Fields(command), "")
+				l.HandleCommand(parts[0], parts[1], parts[2])
+			}
+
+			session := l.session("ash")
+			if paired := session != nil && session == l.session("gary"); paired != tt.wantPaired {
+				t.Errorf("ash and gary paired = %v, want %v", paired, tt.wantPaired)
+			}
+			if session != nil {
+				session.Submit(Command{Username: "ash", Name: "quit"})
+			}
+			if !strings.Contains(ash.String(), tt.wantText) {
+				t.Errorf("ash was sent %q, want %q in it", ash.String(), tt.wantText)
+			}
+		})
+	}
+}
+
+func TestLobby_teamNotReady(t *testing.T) {
+	l := NewLobby()
+	conn := &recordConn{}
+	if _, err := l.AddUser("ash", conn); err != nil {
+		t.Fatal(err)
+	}
+	joinLobby(t, l, "gary")
+	l.HandleCommand("gary", "queue", "")
+	l.HandleCommand("ash", "queue", "")
+	if l.session("ash") != nil {
+		t.Error("a user without a team was paired")
+	}
+	if !strings.Contains(conn.String(), "Choose your team before battling.") {
+		t.Errorf("ash was sent %q, want to be told to choose a team", conn.String())
+	}
+}
+
+func TestLobby_session(t *testing.T) {
+	l := NewLobby()
+	ash := joinLobby(t, l, "ash")
+	gary := joinLobby(t, l, "gary")
+	l.HandleCommand("ash", "queue", "")
+	l.HandleCommand("gary", "queue", "")
+	session := l.session("ash")
+	if session == nil {
+		t.Fatal("ash and gary weren't paired")
+	}
+
+	// Teams are fixed during a battle, and commands go to the battle
+	if err := l.UpdateTeam("ash", []protocol.TeamMember{{Name: "Charizard"}, {Name: "Bulbasaur"}, {Name: "Squirtle"}}); err == nil {
+		t.Error("UpdateTeam() during a battle succeeded, want an error")
+	}
+	l.HandleCommand("ash", "quit", "")
+	select {
+	case <-session.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the battle didn't end when ash quit")
+	}
+
+	// Both users are back in the lobby once the battle is over
+	deadline := time.Now().Add(5 * time.Second)
+	for l.session("ash") != nil || l.session("gary") != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("the users never left the finished battle")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for name, conn := range map[string]*recordConn{"ash": ash, "gary": gary} {
+		for _, want := range []string{"The winner is gary!", "You are back in the lobby."} {
+			if !strings.Contains(conn.String(), want) {
+				t.Errorf("%s was sent %q, want %q in it", name, conn.String(), want)
+			}
+		}
+	}
+}
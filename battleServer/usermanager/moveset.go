@@ -0,0 +1,169 @@
+package usermanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/growth"
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+const (
+	movesetSize    = 4
+	struggleMoveID = "165"
+)
+
+// MoveSlot is one of the moves a battling Pokemon knows, with its remaining PP.
+type MoveSlot struct {
+	Move  *models.Move
+	PP    int
+	MaxPP int
+}
+
+func newMoveSlot(move *models.Move) *MoveSlot {
+	pp, ok := intValue(move.PP)
+	if !ok || pp < 1 {
+		pp = 1
+	}
+	return &MoveSlot{Move: move, PP: pp, MaxPP: pp}
+}
+
+// buildMoveset picks the moves a Pokemon battles with. Requested moves are
+// matched by identifier or name against the Pokemon's learnset; when none are
+// requested the highest level-up moves it knows at its level are used.
+func buildMoveset(pokemon *PokemonData, requested []string) ([]*MoveSlot, error) {
+	if len(requested) > movesetSize {
+		return nil, fmt.Errorf("%s can only know %d moves", pokemon.Monster.Name, movesetSize)
+	}
+
+	var moveset []*MoveSlot
+	for _, name := range requested {
+		move := findLearnableMove(pokemon, name)
+		if move == nil {
+			return nil, fmt.Errorf("%s cannot learn %s", pokemon.Monster.Name, name)
+		}
+		for _, slot := range moveset {
+			if slot.Move == move {
+				return nil, fmt.Errorf("%s already knows %s", pokemon.Monster.Name, move.Name)
+			}
+		}
+		moveset = append(moveset, newMoveSlot(move))
+	}
+	if len(moveset) > 0 {
+		return moveset, nil
+	}
+
+	for _, move := range defaultMoves(pokemon) {
+		moveset = append(moveset, newMoveSlot(move))
+	}
+	if len(moveset) == 0 {
+		return nil, fmt.Errorf("%s has no moves to battle with", pokemon.Monster.Name)
+	}
+	return moveset, nil
+}
+
+func findLearnableMove(pokemon *PokemonData, name string) *models.Move {
+	for _, move := range pokemon.MonsterMoves {
+		if strings.EqualFold(move.Identifier, name) || strings.EqualFold(move.Name, name) {
+			return move
+		}
+	}
+	return nil
+}
+
+// defaultMoves returns the moves a Pokemon knows by its level, as growth
+// teaches them, topped up with the usable moves of its learnset when it knows
+// fewer than movesetSize, as when its level-up data is missing.
+func defaultMoves(pokemon *PokemonData) []*models.Move {
+	var moves []*models.Move
+	species, err := growth.LoadSpecies(DataPath, pokemon.Monster.NationalID)
+	if err != nil {
+		fmt.Printf("Error reading learnset for %s: %v\n", pokemon.Monster.Name, err)
+	} else {
+		for _, identifier := range growth.DefaultMoves(species, pokemonLevel(pokemon)) {
+			if move := findLearnableMove(pokemon, identifier); move != nil {
+				moves = append(moves, move)
+			}
+		}
+	}
+	for _, move := range pokemon.MonsterMoves {
+		if len(moves) == movesetSize {
+			break
+		}
+		if usableMove(move) && !containsMove(moves, move) {
+			moves = append(moves, move)
+		}
+	}
+	return moves
+}
+
+// usableMove reports whether a move does something in battle: it either deals
+// damage or has an effect in the move effects data.
+func usableMove(move *models.Move) bool {
+	if power, _ := movePower(move); power > 0 {
+		return true
+	}
+	_, ok := moveEffect(move)
+	return ok
+}
+
+func containsMove(moves []*models.Move, move *models.Move) bool {
+	for _, m := range moves {
+		if m == move {
+			return true
+		}
+	}
+	return false
+}
+
+// readStruggle loads Struggle, which a Pokemon uses once all its moves are out
+// of PP.
+func readStruggle() (*models.Move, error) {
+	data, err := os.ReadFile(fmt.Sprintf("%s/moves/data/%s.json", DataPath, struggleMoveID))
+	if err != nil {
+		return nil, err
+	}
+	var move models.Move
+	if err := json.Unmarshal(data, &move); err != nil {
+		return nil, err
+	}
+	return &move, nil
+}
+
+// selectMove resolves a player's move choice, given as a 1-based index or a
+// move name, against the active Pokemon's moveset.
+func selectMove(pokemon *PokemonData, choice string) (*MoveSlot, error) {
+	if index, err := strconv.Atoi(choice); err == nil {
+		if index < 1 || index > len(pokemon.Moveset) {
+			return nil, fmt.Errorf("choose a move between 1 and %d", len(pokemon.Moveset))
+		}
+		return pokemon.Moveset[index-1], nil
+	}
+	for _, slot := range pokemon.Moveset {
+		if strings.EqualFold(slot.Move.Identifier, choice) || strings.EqualFold(slot.Move.Name, choice) {
+			return slot, nil
+		}
+	}
+	return nil, fmt.Errorf("%s does not know %s", pokemon.Monster.Name, choice)
+}
+
+func hasPPLeft(pokemon *PokemonData) bool {
+	for _, slot := range pokemon.Moveset {
+		if slot.PP > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// movesetMessage lists the moves of a Pokemon with their type and PP.
+func movesetMessage(pokemon *PokemonData) string {
+	var lines []string
+	for i, slot := range pokemon.Moveset {
+		lines = append(lines, fmt.Sprintf("  %d. %s (%s) PP %d/%d", i+1, slot.Move.Name, slot.Move.TypeName, slot.PP, slot.MaxPP))
+	}
+	return strings.Join(lines, "\n")
+}
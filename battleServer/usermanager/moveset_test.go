@@ -0,0 +1,81 @@
+package usermanager
+
+import (
+	"testing"
+)
+
+func init() {
+	DataPath = "../../internal/models"
+}
+
+func Test_buildMoveset(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested []string
+		want      []string
+		wantErr   bool
+	}{
+		{name: "default level-up moves", want: []string{"flame-burst", "wing-attack", "slash", "flamethrower"}},
+		{name: "requested moves", requested: []string{"Earthquake", "dragon-claw"}, want: []string{"earthquake", "dragon-claw"}},
+		{name: "unknown move", requested: []string{"surf"}, wantErr: true},
+		{name: "duplicate move", requested: []string{"slash", "slash"}, wantErr: true},
+		{name: "too many moves", requested: []string{"slash", "fly", "earthquake", "flamethrower", "ember"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			charizard, err := readPokemonJSONData(DataPath + "/monsters/data/6.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := buildMoveset(charizard, tt.requested)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildMoveset() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("buildMoveset() returned %d moves, want %d", len(got), len(tt.want))
+			}
+			for i, slot := range got {
+				if slot.Move.Identifier != tt.want[i] {
+					t.Errorf("move %d = %s, want %s", i+1, slot.Move.Identifier, tt.want[i])
+				}
+				if slot.PP != slot.MaxPP || slot.PP < 1 {
+					t.Errorf("move %d has PP %d/%d", i+1, slot.PP, slot.MaxPP)
+				}
+			}
+		})
+	}
+}
+
+func Test_selectMove(t *testing.T) {
+	charizard, err := readPokemonJSONData(DataPath + "/monsters/data/6.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	charizard.Moveset, err = buildMoveset(charizard, []string{"slash", "fly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		choice  string
+		want    string
+		wantErr bool
+	}{
+		{name: "by index", choice: "2", want: "fly"},
+		{name: "by name", choice: "Slash", want: "slash"},
+		{name: "index out of range", choice: "3", wantErr: true},
+		{name: "unknown move", choice: "ember", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectMove(charizard, tt.choice)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectMove() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Move.Identifier != tt.want {
+				t.Errorf("selectMove() = %s, want %s", got.Move.Identifier, tt.want)
+			}
+		})
+	}
+}
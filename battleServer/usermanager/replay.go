@@ -0,0 +1,208 @@
+package usermanager
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/protocol"
+)
+
+// Types of replay events.
+const (
+	ReplayStart    = "start"
+	ReplayAction   = "action"
+	ReplayForfeit  = "forfeit"
+	ReplayDamage   = "damage"
+	ReplayRound    = "round"
+	ReplayKnockout = "knockout"
+	ReplayResult   = "result"
+)
+
+// ReplayEvent is one line of a battle's replay log. The log starts with the
+// teams and RNG seed, so re-running the recorded actions through the engine
+// plays the battle out exactly as it happened.
+type ReplayEvent struct {
+	Type  string `json:"type"`
+	Round int    `json:"round,omitempty"`
+	// Seed, Positions and Teams are set on the start event.
+	Seed      int64        `json:"seed,omitempty"`
+	Positions int          `json:"positions,omitempty"`
+	Teams     []ReplayTeam `json:"teams,omitempty"`
+	// Username is the user acting, forfeiting or losing a Pokemon.
+	Username string `json:"username,omitempty"`
+	// Command and Arg are the command of an action event. Bot is set when a
+	// bot chose it.
+	Command string `json:"command,omitempty"`
+	Arg     string `json:"arg,omitempty"`
+	Bot     bool   `json:"bot,omitempty"`
+	// Target, Move, Damage, HP, Critical and Missed describe a damage event.
+	Target   string `json:"target,omitempty"`
+	Move     string `json:"move,omitempty"`
+	Damage   int    `json:"damage,omitempty"`
+	HP       int    `json:"hp,omitempty"`
+	Critical bool   `json:"critical,omitempty"`
+	Missed   bool   `json:"missed,omitempty"`
+	// Pokemon is the Pokemon knocked out in a knockout event.
+	Pokemon string `json:"pokemon,omitempty"`
+	// Lines is the summary of a round shown to the players.
+	Lines  []string `json:"lines,omitempty"`
+	Winner string   `json:"winner,omitempty"`
+}
+
+// ReplayTeam is a user's team as it was at the start of a battle.
+type ReplayTeam struct {
+	Username string `json:"username"`
+	// Bot is the difficulty of a bot's team, or empty for a human.
+	Bot  string                `json:"bot,omitempty"`
+	Team []protocol.TeamMember `json:"team"`
+	// Caught holds, by team position, the pokeCat individual each Pokemon
+	// was built from, or null for one built from its species.
+	Caught []*CaughtPokemon `json:"caught,omitempty"`
+}
+
+// record writes an event to the battle's replay log, if it has one.
+func (um *UserManager) record(event ReplayEvent) {
+	if um.ReplayLog == nil {
+		return
+	}
+	if err := json.NewEncoder(um.ReplayLog).Encode(event); err != nil {
+		fmt.Printf("Error writing replay log: %v\n", err)
+	}
+}
+
+// recordStart writes the seed, the number of positions and every user's team
+// to the replay log.
+func (um *UserManager) recordStart() {
+	event := ReplayEvent{Type: ReplayStart, Seed: um.seed, Positions: um.Positions}
+	for _, name := range um.getPlayerNames() {
+		user := um.Users[name]
+		team := ReplayTeam{Username: name}
+		if user.Bot != nil {
+			team.Bot = user.Bot.Difficulty
+		}
+		for _, pokemon := range user.PokemonData {
+			member := protocol.TeamMember{Name: pokemon.Monster.Name, Level: pokemon.Level}
+			for _, slot := range pokemon.Moveset {
+				member.Moves = append(member.Moves, slot.Move.Identifier)
+			}
+			team.Team = append(team.Team, member)
+			team.Caught = append(team.Caught, pokemon.Caught)
+		}
+		if !anyCaught(team.Caught) {
+			team.Caught = nil
+		}
+		event.Teams = append(event.Teams, team)
+	}
+	um.record(event)
+}
+
+func anyCaught(caught []*CaughtPokemon) bool {
+	for _, c := range caught {
+		if c != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadReplay reads a replay log.
+func ReadReplay(r io.Reader) ([]ReplayEvent, error) {
+	var events []ReplayEvent
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var event ReplayEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(events) == 0 || events[0].Type != ReplayStart {
+		return nil, fmt.Errorf("replay log does not start with the teams")
+	}
+	return events, nil
+}
+
+// VerifyReplay re-runs a recorded battle through the engine with the same
+// seed, teams and actions, and checks every event it produces matches the
+// log. It returns the winner of the re-run battle.
+func VerifyReplay(events []ReplayEvent) (string, error) {
+	if len(events) == 0 || events[0].Type != ReplayStart {
+		return "", fmt.Errorf("replay log does not start with the teams")
+	}
+	start := events[0]
+
+	var log bytes.Buffer
+	um := NewUserManagerWithSeed(start.Seed)
+	um.ReplayLog = &log
+	um.Positions = start.Positions
+	for _, team := range start.Teams {
+		user := &User{Username: team.Username, Pokemons: make([]string, len(team.Team))}
+		if team.Bot != "" {
+			user.Bot = &Bot{Difficulty: team.Bot}
+		}
+		for i, member := range team.Team {
+			if err := user.UpdateTeamMember(i+1, member); err != nil {
+				return "", fmt.Errorf("%s's Pokemon %d: %v", team.Username, i+1, err)
+			}
+			if i < len(team.Caught) && team.Caught[i] != nil {
+				user.PokemonData[i].applyCaught(team.Caught[i])
+			}
+		}
+		um.JoinUser(user)
+	}
+
+	// Bots choose again on their own, so only the humans' actions and
+	// forfeits are fed back in
+	um.StartBattle()
+	um.playBots()
+	for _, event := range events {
+		if !um.BattleStarted {
+			break
+		}
+		switch {
+		case event.Type == ReplayAction && !event.Bot:
+			um.handleCommand(Command{Username: event.Username, Name: event.Command, Arg: event.Arg})
+		case event.Type == ReplayForfeit:
+			um.forfeit(event.Username)
+		default:
+			continue
+		}
+		um.playBots()
+	}
+
+	rerun, err := ReadReplay(&log)
+	if err != nil {
+		return "", err
+	}
+	for i, event := range events {
+		if i >= len(rerun) {
+			return "", fmt.Errorf("the re-run battle ended after %d events, the log has %d", len(rerun), len(events))
+		}
+		want, _ := json.Marshal(event)
+		got, _ := json.Marshal(rerun[i])
+		if !bytes.Equal(want, got) {
+			return "", fmt.Errorf("event %d differs: log has %s, re-run has %s", i+1, want, got)
+		}
+	}
+	if len(rerun) > len(events) {
+		return "", fmt.Errorf("the re-run battle has %d events, the log has %d", len(rerun), len(events))
+	}
+
+	var winner string
+	for _, event := range rerun {
+		if event.Type == ReplayResult {
+			winner = event.Winner
+		}
+	}
+	return winner, nil
+}
@@ -0,0 +1,107 @@
+package usermanager
+
+import (
+	"bytes"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// playRecordedBattle plays a human, who always uses their first move with PP
+// left, against a bot in the format and returns the replay log.
+func playRecordedBattle(t *testing.T, seed int64, format string) []ReplayEvent {
+	t.Helper()
+	rng := rand.New(rand.NewSource(seed))
+	var log bytes.Buffer
+	um := NewUserManagerWithSeed(seed)
+	um.ReplayLog = &log
+	um.Positions = Formats[format].Positions
+
+	human, err := NewBotUser("human", DifficultyRandom, Formats[format], rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	human.Bot = nil
+	bot, err := NewBotUser("bot", DifficultyLookahead, Formats[format], rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	um.JoinUser(human)
+	um.JoinUser(bot)
+
+	um.StartBattle()
+	um.playBots()
+	for turns := 0; um.BattleStarted; turns++ {
+		if turns > 1000 {
+			t.Fatal("battle never finished")
+		}
+		cmd := Command{Username: "human", Name: "move", Arg: "1"}
+		if position := human.choosing(); position >= 0 {
+			if slots := usableSlots(human.Active[position]); len(slots) > 0 {
+				cmd.Arg = strconv.Itoa(slots[0])
+			}
+		}
+		if human.AwaitingSwitch {
+			for i, pokemon := range human.PokemonData {
+				if human.switchable(pokemon) {
+					cmd = Command{Username: "human", Name: "switch", Arg: strconv.Itoa(i + 1)}
+					break
+				}
+			}
+		}
+		um.handleCommand(cmd)
+		um.playBots()
+	}
+
+	events, err := ReadReplay(&log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestVerifyReplay(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		tamper  func(events []ReplayEvent)
+		wantErr bool
+	}{
+		{name: "untouched log"},
+		{name: "untouched doubles log", format: "doubles"},
+		{name: "changed damage", tamper: func(events []ReplayEvent) {
+			for i := range events {
+				if events[i].Type == ReplayDamage && !events[i].Missed {
+					events[i].Damage++
+					return
+				}
+			}
+		}, wantErr: true},
+		{name: "changed winner", tamper: func(events []ReplayEvent) {
+			last := &events[len(events)-1]
+			last.Winner += "!"
+		}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format := tt.format
+			if format == "" {
+				format = DefaultFormat
+			}
+			events := playRecordedBattle(t, 7, format)
+			if events[len(events)-1].Type != ReplayResult {
+				t.Fatalf("last event = %s, want %s", events[len(events)-1].Type, ReplayResult)
+			}
+			if tt.tamper != nil {
+				tt.tamper(events)
+			}
+			winner, err := VerifyReplay(events)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyReplay() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && winner != events[len(events)-1].Winner {
+				t.Errorf("VerifyReplay() winner = %s, want %s", winner, events[len(events)-1].Winner)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package usermanager
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/growth"
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+// earnRewards credits a Pokemon with the EV yield and experience of one it
+// knocked out.
+func earnRewards(winner, knockedOut *PokemonData) {
+	winner.EVsEarned = winner.EVsEarned.Add(models.EVYield(knockedOut.MonsterSupplemental))
+	winner.ExperienceEarned += models.ExperienceYield(knockedOut.Monster, pokemonLevel(knockedOut))
+}
+
+// awardRewards gives the caught Pokemon of both teams the EVs and experience
+// they earned in the battle, levelling them up and evolving them as they
+// grow, and tells their trainers. Pokemon built from a species name only
+// last one battle, and bots keep nothing, so they aren't rewarded.
+func (um *UserManager) awardRewards() {
+	for _, name := range um.getPlayerNames() {
+		user := um.Users[name]
+		if user.Bot != nil {
+			continue
+		}
+		for _, pokemon := range user.PokemonData {
+			if pokemon.Caught == nil {
+				continue
+			}
+			evs, experience := pokemon.EVsEarned, pokemon.ExperienceEarned
+			pokemon.EVsEarned, pokemon.ExperienceEarned = models.Stats{}, 0
+
+			if message := evsMessage(pokemon.Instance.AddEVs(evs)); message != "" {
+				um.sendMessageToUser(user, fmt.Sprintf("Your %s gained %s.", pokemon.Caught.Name, message))
+			}
+			if experience == 0 {
+				continue
+			}
+			species, messages, err := growth.Gain(DataPath, pokemon.Instance, experience)
+			if err != nil {
+				fmt.Printf("Error growing %s's %s: %v\n", user.Username, pokemon.Caught.Name, err)
+			}
+			if species != nil {
+				pokemon.Caught.Name = species.Monster.Name
+			}
+			for _, message := range messages {
+				um.sendMessageToUser(user, message)
+			}
+		}
+	}
+}
+
+// evsMessage lists EVs gained, such as "2 Speed EVs and 1 Attack EV".
+func evsMessage(evs models.Stats) string {
+	var parts []string
+	for _, ev := range []struct {
+		stat  string
+		value int
+	}{
+		{"HP", evs.HP},
+		{statNames[StatAttack], evs.Attack},
+		{statNames[StatDefense], evs.Defense},
+		{statNames[StatSpAtk], evs.SpAtk},
+		{statNames[StatSpDef], evs.SpDef},
+		{statNames[StatSpeed], evs.Speed},
+	} {
+		switch {
+		case ev.value == 1:
+			parts = append(parts, fmt.Sprintf("1 %s EV", ev.stat))
+		case ev.value > 1:
+			parts = append(parts, fmt.Sprintf("%d %s EVs", ev.value, ev.stat))
+		}
+	}
+	if len(parts) <= 1 {
+		return strings.Join(parts, "")
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+}
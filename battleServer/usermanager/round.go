@@ -0,0 +1,223 @@
+package usermanager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+const (
+	ActionMove   = "move"
+	ActionSwitch = "switch"
+)
+
+// Action is what a user has chosen to do in the current round.
+type Action struct {
+	Kind string
+	// Slot is the moveset slot used by a move action. It is nil when the
+	// Pokemon is out of PP and has to Struggle.
+	Slot *MoveSlot
+	Move *models.Move
+	// Target is the 0-based position of the opponent's Pokemon a move is
+	// aimed at.
+	Target int
+	// SwitchTo is the Pokemon sent in by a switch action.
+	SwitchTo *PokemonData
+}
+
+// movePriority lists the moves that act before or after others regardless of
+// speed. The move data has no priority, so anything missing here is 0.
+var movePriority = map[string]int{
+	"helping-hand":  5,
+	"magic-coat":    4,
+	"snatch":        4,
+	"detect":        4,
+	"endure":        4,
+	"protect":       4,
+	"quick-guard":   3,
+	"wide-guard":    3,
+	"fake-out":      3,
+	"extreme-speed": 2,
+	"feint":         2,
+	"follow-me":     2,
+	"rage-powder":   2,
+	"aqua-jet":      1,
+	"bide":          1,
+	"bullet-punch":  1,
+	"ice-shard":     1,
+	"mach-punch":    1,
+	"quick-attack":  1,
+	"shadow-sneak":  1,
+	"sucker-punch":  1,
+	"vacuum-wave":   1,
+	"vital-throw":   -1,
+	"focus-punch":   -3,
+	"avalanche":     -4,
+	"revenge":       -4,
+	"counter":       -5,
+	"mirror-coat":   -5,
+	"roar":          -6,
+	"whirlwind":     -6,
+	"circle-throw":  -6,
+	"dragon-tail":   -6,
+	"trick-room":    -7,
+}
+
+// submitAction records the action for one of a user's positions. Users with
+// another Pokemon in battle are asked for its action next, and the round is
+// resolved once every position has one.
+func (um *UserManager) submitAction(user *User, position int, action *Action) {
+	user.PendingActions[position] = action
+	if user.choosing() >= 0 {
+		um.promptForMove(user)
+		return
+	}
+	for _, u := range um.Users {
+		if u.choosing() >= 0 {
+			um.sendMessageToUser(user, "Waiting for your opponent...\n")
+			return
+		}
+	}
+	um.resolveRound()
+}
+
+// startRound asks every user for their next action.
+func (um *UserManager) startRound() {
+	um.Round++
+	for _, name := range um.getPlayerNames() {
+		um.promptForMove(um.Users[name])
+	}
+}
+
+// resolveRound carries out the chosen actions in order, sends a summary of the
+// round to both users and then either ends the battle, waits for replacements
+// for knocked out Pokemon or starts the next round.
+func (um *UserManager) resolveRound() {
+	um.roundEvents = nil
+	for _, b := range um.actionOrder() {
+		action := b.action()
+		switch action.Kind {
+		case ActionSwitch:
+			um.logEvent(fmt.Sprintf("%s withdrew %s and sent out %s.", b.user.Username, b.pokemon().Monster.Name, action.SwitchTo.Monster.Name))
+			b.user.sendOut(b.position, action.SwitchTo)
+		case ActionMove:
+			// A Pokemon knocked out earlier in the round doesn't get to
+			// move, and neither does one with nothing left to hit
+			if !b.alive() {
+				continue
+			}
+			target, ok := um.target(b, action.Target)
+			if !ok {
+				continue
+			}
+			if !um.canAct(b) {
+				continue
+			}
+			if action.Slot != nil {
+				action.Slot.PP--
+			} else {
+				um.logEvent(fmt.Sprintf("%s has no moves left!", b.pokemon().Monster.Name))
+			}
+			result := um.calculateAndApplyDamage(b, target, action.Move)
+			um.applyMoveEffect(b, target, action.Move, result)
+		}
+	}
+	for _, name := range um.getPlayerNames() {
+		for _, b := range um.Users[name].battlers() {
+			um.applyStatusDamage(b)
+		}
+	}
+	for _, user := range um.Users {
+		user.PendingActions = make([]*Action, len(user.Active))
+	}
+
+	um.broadcastMessage(fmt.Sprintf("\n--- Round %d ---\n%s\n", um.Round, strings.Join(um.roundEvents, "\n")))
+	um.record(ReplayEvent{Type: ReplayRound, Round: um.Round, Lines: um.roundEvents})
+
+	for _, name := range um.getPlayerNames() {
+		user := um.Users[name]
+		if um.hasLost(user) {
+			um.announceWinner(um.getOpponent(name).Username)
+			return
+		}
+	}
+	for _, name := range um.getPlayerNames() {
+		um.replaceKnockedOutPokemon(um.Users[name])
+	}
+	um.startRoundIfReady()
+}
+
+// startRoundIfReady starts the next round unless a user still has to send in
+// a replacement for a knocked out Pokemon.
+func (um *UserManager) startRoundIfReady() {
+	if um.awaitingReplacement() {
+		return
+	}
+	um.startRound()
+}
+
+// awaitingReplacement reports whether a user still has to send in a Pokemon
+// after a knockout before the next round can begin.
+func (um *UserManager) awaitingReplacement() bool {
+	for _, user := range um.Users {
+		if user.AwaitingSwitch {
+			return true
+		}
+	}
+	return false
+}
+
+// actionOrder sorts the positions with an action this round by the order
+// their actions resolve in: switches first, then by move priority, then by the
+// Pokemon's speed, with ties broken at random.
+func (um *UserManager) actionOrder() []battler {
+	var battlers []battler
+	tieBreak := make(map[battler]int)
+	for _, name := range um.getPlayerNames() {
+		for _, b := range um.Users[name].battlers() {
+			if b.action() == nil {
+				continue
+			}
+			battlers = append(battlers, b)
+			tieBreak[b] = um.rng.Int()
+		}
+	}
+
+	sort.SliceStable(battlers, func(i, j int) bool {
+		a, b := battlers[i], battlers[j]
+		if pa, pb := actionPriority(a.action()), actionPriority(b.action()); pa != pb {
+			return pa > pb
+		}
+		if sa, sb := effectiveSpeed(a.pokemon()), effectiveSpeed(b.pokemon()); sa != sb {
+			return sa > sb
+		}
+		return tieBreak[a] < tieBreak[b]
+	})
+	return battlers
+}
+
+// actionPriority ranks an action within a round. Switching always goes before
+// any move.
+func actionPriority(action *Action) int {
+	if action.Kind == ActionSwitch {
+		return 100
+	}
+	return movePriority[action.Move.Identifier]
+}
+
+// effectiveSpeed returns the speed a Pokemon currently acts at, after its
+// speed stage. Paralysis halves it.
+func effectiveSpeed(pokemon *PokemonData) int {
+	speed := modifiedStat(pokemon, StatSpeed)
+	if pokemon.Status == StatusParalysis {
+		return speed / 2
+	}
+	return speed
+}
+
+// logEvent adds a line to the summary of the current round.
+func (um *UserManager) logEvent(message string) {
+	um.roundEvents = append(um.roundEvents, message)
+}
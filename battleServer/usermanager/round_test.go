@@ -0,0 +1,36 @@
+package usermanager
+
+import (
+	"testing"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+func Test_actionOrder(t *testing.T) {
+	tackle := &Action{Kind: ActionMove, Move: &models.Move{Identifier: "tackle"}}
+	quickAttack := &Action{Kind: ActionMove, Move: &models.Move{Identifier: "quick-attack"}}
+	counter := &Action{Kind: ActionMove, Move: &models.Move{Identifier: "counter"}}
+	switchOut := &Action{Kind: ActionSwitch}
+
+	tests := []struct {
+		name       string
+		slowAction *Action
+		fastAction *Action
+		wantFirst  string
+	}{
+		{name: "faster moves first", slowAction: tackle, fastAction: tackle, wantFirst: "fast"},
+		{name: "priority beats speed", slowAction: quickAttack, fastAction: tackle, wantFirst: "slow"},
+		{name: "negative priority goes last", slowAction: tackle, fastAction: counter, wantFirst: "slow"},
+		{name: "switch goes before moves", slowAction: switchOut, fastAction: quickAttack, wantFirst: "slow"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			um := NewUserManagerWithSeed(1)
+			um.Users["slow"] = &User{Username: "slow", Active: []*PokemonData{{Monster: &models.Monster{Speed: 30}}}, PendingActions: []*Action{tt.slowAction}}
+			um.Users["fast"] = &User{Username: "fast", Active: []*PokemonData{{Monster: &models.Monster{Speed: 90}}}, PendingActions: []*Action{tt.fastAction}}
+			if got := um.actionOrder()[0].user.Username; got != tt.wantFirst {
+				t.Errorf("actionOrder() first = %s, want %s", got, tt.wantFirst)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package usermanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultFormat is the battle format used when none is chosen.
+const DefaultFormat = "standard"
+
+// maxTeamSize is the most Pokemon a team may have.
+const maxTeamSize = 6
+
+// Ruleset is the set of rules a team must follow to battle in a format. Teams
+// are checked against it when they are submitted. Formats beyond the built-in
+// ones can be loaded from a JSON file with LoadFormats.
+type Ruleset struct {
+	Format string `json:"format"`
+	// TeamSize is the number of Pokemon each user brings, 1 to 6.
+	TeamSize int `json:"team_size"`
+	// Positions is how many Pokemon each user has in battle at once: 1 for
+	// singles, 2 for doubles. Zero means 1.
+	Positions int `json:"positions,omitempty"`
+	// SpeciesClause allows only one Pokemon of each species on a team.
+	SpeciesClause bool `json:"species_clause,omitempty"`
+	// Banned lists the species that may not be used, by name.
+	Banned []string `json:"banned,omitempty"`
+	// MaxLevel is the highest level a Pokemon may be. Pokemon with no level
+	// given battle at the lower of it and the default level. Zero means no
+	// cap.
+	MaxLevel int `json:"max_level,omitempty"`
+	// Generations lists the generations whose Pokemon may be used. All are
+	// allowed when it is empty.
+	Generations []int `json:"generations,omitempty"`
+}
+
+// generations gives the national ids of the Pokemon each generation
+// introduced.
+var generations = []struct{ generation, first, last int }{
+	{1, 1, 151},
+	{2, 152, 251},
+	{3, 252, 386},
+	{4, 387, 493},
+	{5, 494, 649},
+}
+
+// legendaries are the legendary and mythical Pokemon, banned from standard
+// play.
+var legendaries = []string{
+	"Articuno", "Zapdos", "Moltres", "Mewtwo", "Mew",
+	"Raikou", "Entei", "Suicune", "Lugia", "Ho-oh", "Celebi",
+	"Regirock", "Regice", "Registeel", "Latias", "Latios", "Kyogre", "Groudon", "Rayquaza", "Jirachi", "Deoxys-normal",
+	"Uxie", "Mesprit", "Azelf", "Dialga", "Palkia", "Heatran", "Regigigas", "Giratina-altered", "Cresselia", "Phione", "Manaphy", "Darkrai", "Shaymin-land", "Arceus",
+	"Victini", "Cobalion", "Terrakion", "Virizion", "Tornadus-incarnate", "Thundurus-incarnate", "Reshiram", "Zekrom", "Landorus-incarnate", "Kyurem", "Keldeo-ordinary", "Meloetta-aria", "Genesect",
+}
+
+// Formats are the battle formats the server knows, by name.
+var Formats = map[string]*Ruleset{
+	"standard": {Format: "standard", TeamSize: 3, SpeciesClause: true, Banned: legendaries, MaxLevel: 100},
+	"ubers":    {Format: "ubers", TeamSize: 3, SpeciesClause: true, MaxLevel: 100},
+	"kanto":    {Format: "kanto", TeamSize: 3, SpeciesClause: true, Banned: []string{"Mewtwo", "Mew"}, MaxLevel: 100, Generations: []int{1}},
+	"little":   {Format: "little", TeamSize: 3, SpeciesClause: true, Banned: legendaries, MaxLevel: 5},
+	"full":     {Format: "full", TeamSize: 6, SpeciesClause: true, Banned: legendaries, MaxLevel: 100},
+	"1v1":      {Format: "1v1", TeamSize: 1, Banned: legendaries, MaxLevel: 100},
+	"doubles":  {Format: "doubles", TeamSize: 4, Positions: 2, SpeciesClause: true, Banned: legendaries, MaxLevel: 100},
+}
+
+// LoadFormats reads a JSON array of rulesets and adds them to Formats,
+// replacing any built-in format of the same name.
+func LoadFormats(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var rulesets []*Ruleset
+	if err := json.Unmarshal(data, &rulesets); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for _, ruleset := range rulesets {
+		if err := ruleset.check(); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	for _, ruleset := range rulesets {
+		Formats[ruleset.Format] = ruleset
+	}
+	return nil
+}
+
+// check makes sure a ruleset describes a battle the engine can play.
+func (r *Ruleset) check() error {
+	switch {
+	case r.Format == "":
+		return fmt.Errorf("a format needs a name")
+	case r.TeamSize < 1 || r.TeamSize > maxTeamSize:
+		return fmt.Errorf("format %s: teams must have 1 to %d Pokemon, not %d", r.Format, maxTeamSize, r.TeamSize)
+	case r.Positions < 0 || r.Positions > r.TeamSize:
+		return fmt.Errorf("format %s: %d Pokemon can't fill %d positions", r.Format, r.TeamSize, r.Positions)
+	case r.MaxLevel < 0:
+		return fmt.Errorf("format %s: the level cap can't be %d", r.Format, r.MaxLevel)
+	}
+	return nil
+}
+
+// TeamError explains why a team was rejected.
+type TeamError struct {
+	Format   string
+	Problems []string
+}
+
+func (e *TeamError) Error() string {
+	return fmt.Sprintf("team is not allowed in the %s format: %s", e.Format, strings.Join(e.Problems, "; "))
+}
+
+// Validate checks a loaded team against every rule of the format.
+func (r *Ruleset) Validate(team []*PokemonData) error {
+	var problems []string
+	if len(team) != r.TeamSize {
+		problems = append(problems, fmt.Sprintf("a team must have exactly %d Pokemon", r.TeamSize))
+	}
+	problems = append(problems, r.problems(team)...)
+	if len(problems) > 0 {
+		return &TeamError{Format: r.Format, Problems: problems}
+	}
+	return nil
+}
+
+// problems lists the ways the Pokemon of a team, complete or not, break the
+// rules.
+func (r *Ruleset) problems(team []*PokemonData) []string {
+	var problems []string
+	seen := make(map[string]bool)
+	for _, pokemon := range team {
+		name := pokemon.Monster.Name
+		if r.SpeciesClause && seen[name] {
+			problems = append(problems, fmt.Sprintf("only one %s is allowed", name))
+		}
+		seen[name] = true
+
+		if r.banned(name) {
+			problems = append(problems, fmt.Sprintf("%s is banned", name))
+		}
+		if level := pokemonLevel(pokemon); r.MaxLevel > 0 && level > r.MaxLevel {
+			problems = append(problems, fmt.Sprintf("%s is level %d but the highest level allowed is %d", name, level, r.MaxLevel))
+		}
+		if !r.allowedGeneration(pokemon.Monster.NationalID) {
+			problems = append(problems, fmt.Sprintf("%s is not from generation %s", name, joinInts(r.Generations)))
+		}
+	}
+	return problems
+}
+
+// DefaultLevel is the level of Pokemon submitted without one.
+func (r *Ruleset) DefaultLevel() int {
+	if r.MaxLevel > 0 && r.MaxLevel < defaultLevel {
+		return r.MaxLevel
+	}
+	return defaultLevel
+}
+
+// Describe summarises the rules for players.
+func (r *Ruleset) Describe() string {
+	rules := []string{fmt.Sprintf("%d Pokemon per team", r.TeamSize)}
+	if r.Positions > 1 {
+		rules = append(rules, fmt.Sprintf("%d in battle at once", r.Positions))
+	}
+	if r.SpeciesClause {
+		rules = append(rules, "one of each species")
+	}
+	if r.MaxLevel > 0 {
+		rules = append(rules, fmt.Sprintf("levels up to %d", r.MaxLevel))
+	}
+	if len(r.Generations) > 0 {
+		rules = append(rules, fmt.Sprintf("generation %s only", joinInts(r.Generations)))
+	}
+	if len(r.Banned) > 0 {
+		rules = append(rules, fmt.Sprintf("%d banned Pokemon", len(r.Banned)))
+	}
+	return fmt.Sprintf("%s: %s", r.Format, strings.Join(rules, ", "))
+}
+
+func (r *Ruleset) banned(name string) bool {
+	for _, banned := range r.Banned {
+		if strings.EqualFold(banned, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Ruleset) allowedGeneration(nationalID int) bool {
+	if len(r.Generations) == 0 {
+		return true
+	}
+	for _, allowed := range r.Generations {
+		for _, g := range generations {
+			if g.generation == allowed && nationalID >= g.first && nationalID <= g.last {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func joinInts(values []int) string {
+	var parts []string
+	for _, v := range values {
+		parts = append(parts, fmt.Sprint(v))
+	}
+	return strings.Join(parts, " or ")
+}
@@ -0,0 +1,97 @@
+package usermanager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/protocol"
+)
+
+func TestLobby_UpdateTeam(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		team    []protocol.TeamMember
+		wantErr string
+	}{
+		{name: "legal team", format: "standard", team: []protocol.TeamMember{{Name: "Pikachu"}, {Name: "bulbasaur"}, {Name: "Squirtle", Level: 30}}},
+		{name: "too few Pokemon", format: "standard", team: []protocol.TeamMember{{Name: "Pikachu"}}, wantErr: "exactly 3 Pokemon"},
+		{name: "unknown species", format: "standard", team: []protocol.TeamMember{{Name: "Pikachu"}, {Name: "Missingno"}, {Name: "Squirtle"}}, wantErr: `unknown Pokemon "Missingno"`},
+		{name: "species clause", format: "standard", team: []protocol.TeamMember{{Name: "Pikachu"}, {Name: "Pikachu"}, {Name: "Squirtle"}}, wantErr: "only one Pikachu"},
+		{name: "banned legendary", format: "standard", team: []protocol.TeamMember{{Name: "Mewtwo"}, {Name: "Pikachu"}, {Name: "Squirtle"}}, wantErr: "Mewtwo is banned"},
+		{name: "legendaries allowed in ubers", format: "ubers", team: []protocol.TeamMember{{Name: "Mewtwo"}, {Name: "Pikachu"}, {Name: "Squirtle"}}},
+		{name: "level cap", format: "little", team: []protocol.TeamMember{{Name: "Pichu"}, {Name: "Bulbasaur", Level: 20}, {Name: "Squirtle"}}, wantErr: "highest level allowed is 5"},
+		{name: "generation", format: "kanto", team: []protocol.TeamMember{{Name: "Pikachu"}, {Name: "Chikorita"}, {Name: "Squirtle"}}, wantErr: "Chikorita is not from generation 1"},
+		{name: "single Pokemon", format: "1v1", team: []protocol.TeamMember{{Name: "Pikachu"}}},
+		{name: "six Pokemon", format: "full", team: []protocol.TeamMember{{Name: "Pikachu"}, {Name: "Bulbasaur"}, {Name: "Squirtle"}, {Name: "Charmander"}, {Name: "Eevee"}, {Name: "Snorlax"}}},
+		{name: "too many Pokemon", format: "1v1", team: []protocol.TeamMember{{Name: "Pikachu"}, {Name: "Bulbasaur"}}, wantErr: "exactly 1 Pokemon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lobby := NewLobby()
+			lobby.Ruleset = Formats[tt.format]
+			if _, err := lobby.AddUser("ash", nil); err != nil {
+				t.Fatal(err)
+			}
+
+			err := lobby.UpdateTeam("ash", tt.team)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("UpdateTeam() error = %v", err)
+				}
+				return
+			}
+			var teamErr *TeamError
+			if !errors.As(err, &teamErr) {
+				t.Fatalf("UpdateTeam() error = %v, want a TeamError", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("UpdateTeam() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "singles and doubles", data: `[{"format": "test-singles", "team_size": 6}, {"format": "test-doubles", "team_size": 2, "positions": 2, "max_level": 50}]`},
+		{name: "team too large", data: `[{"format": "test-big", "team_size": 7}]`, wantErr: "1 to 6 Pokemon"},
+		{name: "empty team", data: `[{"format": "test-empty", "team_size": 0}]`, wantErr: "1 to 6 Pokemon"},
+		{name: "more positions than Pokemon", data: `[{"format": "test-triples", "team_size": 2, "positions": 3}]`, wantErr: "can't fill 3 positions"},
+		{name: "unnamed format", data: `[{"team_size": 3}]`, wantErr: "needs a name"},
+		{name: "not JSON", data: `formats`, wantErr: "invalid character"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "formats.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			t.Cleanup(func() {
+				delete(Formats, "test-singles")
+				delete(Formats, "test-doubles")
+			})
+			err := LoadFormats(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("LoadFormats() error = %v", err)
+				}
+				if doubles := Formats["test-doubles"]; doubles == nil || doubles.Positions != 2 || doubles.MaxLevel != 50 {
+					t.Errorf("test-doubles = %+v, want 2 positions and level cap 50", doubles)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadFormats() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package usermanager
+
+import "net"
+
+// Command is a battle command sent by a user to their battle session.
+type Command struct {
+	Username string
+	Name     string
+	Arg      string
+	// Conn is the new connection of a reconnecting user.
+	Conn net.Conn
+	// system marks commands raised by the server itself, such as
+	// disconnects, which users can't send.
+	system bool
+}
+
+// Commands the server raises about a user's connection.
+const (
+	commandDisconnect = "disconnect"
+	commandReconnect  = "reconnect"
+	commandForfeit    = "forfeit"
+	commandWatch      = "watch"
+	commandUnwatch    = "unwatch"
+)
+
+// SubmitDisconnect tells the battle the user's connection was lost.
+func (um *UserManager) SubmitDisconnect(username string) {
+	um.Submit(Command{Username: username, Name: commandDisconnect, system: true})
+}
+
+// SubmitReconnect tells the battle the user is back on a new connection.
+func (um *UserManager) SubmitReconnect(username string, conn net.Conn) {
+	um.Submit(Command{Username: username, Name: commandReconnect, Conn: conn, system: true})
+}
+
+// SubmitWatch adds a read-only spectator on the given connection.
+func (um *UserManager) SubmitWatch(username string, conn net.Conn) {
+	um.Submit(Command{Username: username, Name: commandWatch, Conn: conn, system: true})
+}
+
+// SubmitUnwatch stops sending the battle to a spectator.
+func (um *UserManager) SubmitUnwatch(username string) {
+	um.Submit(Command{Username: username, Name: commandUnwatch, system: true})
+}
+
+// Submit queues a command for the battle's goroutine. Commands sent after the
+// battle is over are dropped.
+func (um *UserManager) Submit(cmd Command) {
+	select {
+	case um.commands <- cmd:
+	case <-um.done:
+	}
+}
+
+// Run starts the battle and processes commands until it is over. All battle
+// state is owned by the goroutine running it.
+func (um *UserManager) Run() {
+	defer func() {
+		um.stopForfeitTimers()
+		um.closeSpectators()
+		close(um.done)
+		if um.OnFinish != nil {
+			um.OnFinish(um)
+		}
+	}()
+
+	um.StartBattle()
+	um.playBots()
+	for um.BattleStarted {
+		um.handleCommand(<-um.commands)
+		um.playBots()
+	}
+}
+
+func (um *UserManager) handleCommand(cmd Command) {
+	if cmd.system {
+		switch cmd.Name {
+		case commandDisconnect:
+			um.userDisconnected(cmd.Username)
+		case commandReconnect:
+			um.userReconnected(cmd.Username, cmd.Conn)
+		case commandForfeit:
+			um.forfeitIfDisconnected(cmd.Username)
+		case commandWatch:
+			um.addSpectator(cmd.Username, cmd.Conn)
+		case commandUnwatch:
+			um.removeSpectator(cmd.Username)
+		}
+		return
+	}
+
+	um.record(ReplayEvent{Type: ReplayAction, Round: um.Round, Username: cmd.Username, Command: cmd.Name, Arg: cmd.Arg, Bot: um.isBot(cmd.Username)})
+	switch cmd.Name {
+	case "switch":
+		um.SwitchPokemon(cmd.Username, cmd.Arg)
+	case "move":
+		um.PerformBattle(cmd.Arg, cmd.Username)
+	case "quit":
+		um.PerformBattle("quit", cmd.Username)
+	default:
+		// Anything else is a move given directly by number or name, or 'quit'
+		um.PerformBattle(cmd.Name, cmd.Username)
+	}
+}
+
+// Done is closed once the battle is over.
+func (um *UserManager) Done() <-chan struct{} {
+	return um.done
+}
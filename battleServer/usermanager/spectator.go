@@ -0,0 +1,110 @@
+package usermanager
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/protocol"
+)
+
+// spectatorBuffer is how many messages may queue up for a spectator before
+// they are dropped from the battle.
+const spectatorBuffer = 64
+
+// spectator is someone watching a battle. Their messages are written on their
+// own goroutine, so a slow spectator can't hold up the battle.
+type spectator struct {
+	outgoing chan protocol.Message
+}
+
+func newSpectator(username string, conn net.Conn) *spectator {
+	s := &spectator{outgoing: make(chan protocol.Message, spectatorBuffer)}
+	go func() {
+		for msg := range s.outgoing {
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := protocol.Write(conn, msg); err != nil {
+				fmt.Printf("Error sending message to spectator %s: %v\n", username, err)
+				// Keep draining so the battle never blocks on us
+				for range s.outgoing {
+				}
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// addSpectator starts sending the battle's public events to a user, beginning
+// with what can be seen of the battle so far.
+func (um *UserManager) addSpectator(username string, conn net.Conn) {
+	if _, playing := um.Users[username]; playing {
+		return
+	}
+	um.removeSpectator(username)
+	s := newSpectator(username, conn)
+	um.spectators[username] = s
+	s.outgoing <- protocol.StateMessage(um.spectatorSummary())
+}
+
+func (um *UserManager) removeSpectator(username string) {
+	if s, ok := um.spectators[username]; ok {
+		close(s.outgoing)
+		delete(um.spectators, username)
+	}
+}
+
+// sendToSpectators queues a message for every spectator, dropping any who
+// have fallen too far behind.
+func (um *UserManager) sendToSpectators(msg protocol.Message) {
+	for username, s := range um.spectators {
+		select {
+		case s.outgoing <- msg:
+		default:
+			um.removeSpectator(username)
+		}
+	}
+}
+
+func (um *UserManager) closeSpectators() {
+	for username := range um.spectators {
+		um.removeSpectator(username)
+	}
+}
+
+// spectatorSummary describes the battle as a spectator may see it: the
+// Pokemon that have been sent out and how many each user has left.
+func (um *UserManager) spectatorSummary() string {
+	names := um.getPlayerNames()
+	lines := []string{fmt.Sprintf("You are watching %s, round %d. Type 'unwatch' to stop.", strings.Join(names, " vs "), um.Round)}
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("%s's team:\n%s", name, revealedTeamMessage(um.Users[name])))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// revealedTeamMessage lists the user's Pokemon that have been sent out, and
+// how many are still unseen.
+func revealedTeamMessage(user *User) string {
+	var lines []string
+	hidden := 0
+	for _, pokemon := range user.PokemonData {
+		if !pokemon.Revealed {
+			hidden++
+			continue
+		}
+		status := fmt.Sprintf("%d/%d HP%s", pokemon.CurrentHP, pokemon.maxHP(), statusLabel(pokemon))
+		switch {
+		case pokemon.CurrentHP <= 0:
+			status = "fainted"
+		case user.isActive(pokemon):
+			status += " (active)"
+		}
+		lines = append(lines, fmt.Sprintf("  %s %s", pokemon.Monster.Name, status))
+	}
+	if hidden > 0 {
+		lines = append(lines, fmt.Sprintf("  %d not yet seen", hidden))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,169 @@
+package usermanager
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+// Stats that moves can raise or lower, named as in the monster data.
+const (
+	StatAttack   = "attack"
+	StatDefense  = "defense"
+	StatSpAtk    = "sp_atk"
+	StatSpDef    = "sp_def"
+	StatSpeed    = "speed"
+	StatAccuracy = "accuracy"
+	StatEvasion  = "evasion"
+)
+
+const (
+	minStatStage = -6
+	maxStatStage = 6
+)
+
+// statOrder lists the stats in the order they are shown to players.
+var statOrder = []string{StatAttack, StatDefense, StatSpAtk, StatSpDef, StatSpeed, StatAccuracy, StatEvasion}
+
+var statNames = map[string]string{
+	StatAttack:   "Attack",
+	StatDefense:  "Defense",
+	StatSpAtk:    "Special Attack",
+	StatSpDef:    "Special Defense",
+	StatSpeed:    "Speed",
+	StatAccuracy: "accuracy",
+	StatEvasion:  "evasiveness",
+}
+
+// stageMultiplier scales a battle stat by its stage: +1 is x1.5, +6 is x4, -1
+// is x2/3 and -6 is x1/4.
+func stageMultiplier(stage int) float64 {
+	if stage >= 0 {
+		return float64(2+stage) / 2
+	}
+	return 2 / float64(2-stage)
+}
+
+// accuracyMultiplier scales a move's accuracy by the attacker's accuracy stage
+// less the defender's evasion stage, which move in thirds rather than halves.
+func accuracyMultiplier(attacker, defender *PokemonData) float64 {
+	stage := clampStage(attacker.StatStages[StatAccuracy] - defender.StatStages[StatEvasion])
+	if stage >= 0 {
+		return float64(3+stage) / 3
+	}
+	return 3 / float64(3-stage)
+}
+
+// perfectIVs are the IVs of Pokemon built from a species name, so that two
+// teams naming the same species battle on equal terms.
+var perfectIVs = models.Stats{HP: models.MaxIV, Attack: models.MaxIV, Defense: models.MaxIV, SpAtk: models.MaxIV, SpDef: models.MaxIV, Speed: models.MaxIV}
+
+// stats returns the Pokemon's stats, computed from its species and the
+// individual it is. A Pokemon without an individual has its species' base
+// stats.
+func (p *PokemonData) stats() models.Stats {
+	if p.Instance == nil {
+		return models.BaseStats(p.Monster)
+	}
+	return p.Instance.Stats(p.Monster)
+}
+
+// maxHP returns the Pokemon's HP when it is fully healed.
+func (p *PokemonData) maxHP() int {
+	return p.stats().HP
+}
+
+// modifiedStat returns one of a Pokemon's stats with its stage applied.
+func modifiedStat(pokemon *PokemonData, stat string) int {
+	stats := pokemon.stats()
+	var value int
+	switch stat {
+	case StatAttack:
+		value = stats.Attack
+	case StatDefense:
+		value = stats.Defense
+	case StatSpAtk:
+		value = stats.SpAtk
+	case StatSpDef:
+		value = stats.SpDef
+	case StatSpeed:
+		value = stats.Speed
+	}
+	return int(float64(value) * stageMultiplier(pokemon.StatStages[stat]))
+}
+
+// changeStatStage raises or lowers a stat of the Pokemon, keeping it within
+// -6..+6.
+func (um *UserManager) changeStatStage(b battler, stat string, stages int) {
+	pokemon := b.pokemon()
+	name := fmt.Sprintf("%s's %s", b.name(), statNames[stat])
+
+	current := pokemon.StatStages[stat]
+	next := clampStage(current + stages)
+	if next == current {
+		if stages > 0 {
+			um.logEvent(fmt.Sprintf("%s won't go any higher!", name))
+		} else {
+			um.logEvent(fmt.Sprintf("%s won't go any lower!", name))
+		}
+		return
+	}
+
+	if pokemon.StatStages == nil {
+		pokemon.StatStages = make(map[string]int)
+	}
+	pokemon.StatStages[stat] = next
+	um.logEvent(fmt.Sprintf("%s %s", name, stageChangeMessage(stages)))
+}
+
+func stageChangeMessage(stages int) string {
+	switch {
+	case stages >= 3:
+		return "rose drastically!"
+	case stages == 2:
+		return "rose sharply!"
+	case stages == 1:
+		return "rose!"
+	case stages == -1:
+		return "fell!"
+	case stages == -2:
+		return "harshly fell!"
+	}
+	return "severely fell!"
+}
+
+func clampStage(stage int) int {
+	if stage < minStatStage {
+		return minStatStage
+	}
+	if stage > maxStatStage {
+		return maxStatStage
+	}
+	return stage
+}
+
+// sendOut puts pokemon in one of the user's positions. Stat stages don't last
+// once a Pokemon is switched out, so the outgoing Pokemon's are cleared.
+func (u *User) sendOut(position int, pokemon *PokemonData) {
+	if out := u.Active[position]; out != nil {
+		out.StatStages = nil
+	}
+	u.Active[position] = pokemon
+	pokemon.Revealed = true
+}
+
+// statStagesLabel lists a Pokemon's raised and lowered stats, e.g.
+// " (Attack +2, Speed -1)".
+func statStagesLabel(pokemon *PokemonData) string {
+	var changes []string
+	for _, stat := range statOrder {
+		if stage := pokemon.StatStages[stat]; stage != 0 {
+			changes = append(changes, fmt.Sprintf("%s %+d", statNames[stat], stage))
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(changes, ", ") + ")"
+}
@@ -0,0 +1,82 @@
+package usermanager
+
+import (
+	"testing"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+func Test_stageMultiplier(t *testing.T) {
+	tests := []struct {
+		name  string
+		stage int
+		want  float64
+	}{
+		{name: "neutral", stage: 0, want: 1},
+		{name: "raised once", stage: 1, want: 1.5},
+		{name: "maxed", stage: 6, want: 4},
+		{name: "lowered twice", stage: -2, want: 0.5},
+		{name: "minimum", stage: -6, want: 0.25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stageMultiplier(tt.stage); got != tt.want {
+				t.Errorf("stageMultiplier(%d) = %v, want %v", tt.stage, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_applyMoveEffect_statChanges(t *testing.T) {
+	tests := []struct {
+		name         string
+		move         string
+		startStage   int
+		wantAttacker map[string]int
+		wantDefender map[string]int
+	}{
+		{name: "swords dance raises the user's attack", move: "swords-dance", wantAttacker: map[string]int{StatAttack: 2}},
+		{name: "growl lowers the target's attack", move: "growl", wantDefender: map[string]int{StatAttack: -1}},
+		{name: "tickle lowers two stats", move: "tickle", wantDefender: map[string]int{StatAttack: -1, StatDefense: -1}},
+		{name: "stages stop at +6", move: "swords-dance", startStage: 5, wantAttacker: map[string]int{StatAttack: 6}},
+		{name: "overheat lowers the user's special attack", move: "overheat", wantAttacker: map[string]int{StatSpAtk: -2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			um := NewUserManagerWithSeed(1)
+			attacker := &User{Username: "attacker", Active: []*PokemonData{{
+				Monster:    &models.Monster{Name: "User"},
+				StatStages: map[string]int{StatAttack: tt.startStage},
+			}}}
+			defender := &User{Username: "defender", Active: []*PokemonData{{
+				Monster:   &models.Monster{Name: "Target", HP: 100},
+				CurrentHP: 100,
+			}}}
+			um.applyMoveEffect(battler{user: attacker}, battler{user: defender}, &models.Move{Identifier: tt.move}, DamageResult{Effectiveness: 1})
+			for stat, want := range tt.wantAttacker {
+				if got := attacker.Active[0].StatStages[stat]; got != want {
+					t.Errorf("attacker %s stage = %d, want %d", stat, got, want)
+				}
+			}
+			for stat, want := range tt.wantDefender {
+				if got := defender.Active[0].StatStages[stat]; got != want {
+					t.Errorf("defender %s stage = %d, want %d", stat, got, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_sendOut(t *testing.T) {
+	first := &PokemonData{Monster: &models.Monster{Name: "First"}, StatStages: map[string]int{StatSpeed: 2}}
+	second := &PokemonData{Monster: &models.Monster{Name: "Second"}}
+	user := &User{Username: "user", Active: []*PokemonData{first}}
+
+	user.sendOut(0, second)
+	if user.Active[0] != second {
+		t.Errorf("active Pokemon = %s, want Second", user.Active[0].Monster.Name)
+	}
+	if first.StatStages[StatSpeed] != 0 {
+		t.Errorf("switched out Pokemon kept its speed stage %d", first.StatStages[StatSpeed])
+	}
+}
@@ -0,0 +1,214 @@
+package usermanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+// Major status conditions. A Pokemon can only have one at a time and keeps it
+// when switched out.
+const (
+	StatusBurn      = "burn"
+	StatusPoison    = "poison"
+	StatusParalysis = "paralysis"
+	StatusSleep     = "sleep"
+	StatusFreeze    = "freeze"
+)
+
+const (
+	paralysisSkipChance = 25 // percent chance a paralyzed Pokemon can't move
+	thawChance          = 20 // percent chance a frozen Pokemon thaws each turn
+	maxSleepTurns       = 3
+)
+
+// statusImmunities lists the types that can't get each status.
+var statusImmunities = map[string][]string{
+	StatusBurn:      {"fire"},
+	StatusPoison:    {"poison", "steel"},
+	StatusParalysis: {"electric"},
+	StatusFreeze:    {"ice"},
+}
+
+var statusLabels = map[string]string{
+	StatusBurn:      "BRN",
+	StatusPoison:    "PSN",
+	StatusParalysis: "PAR",
+	StatusSleep:     "SLP",
+	StatusFreeze:    "FRZ",
+}
+
+var (
+	moveEffects     map[string]models.MoveEffect
+	moveEffectsOnce sync.Once
+)
+
+// moveEffect returns the effect data for a move, loading the effects file the
+// first time it is needed.
+func moveEffect(move *models.Move) (models.MoveEffect, bool) {
+	moveEffectsOnce.Do(func() {
+		data, err := os.ReadFile(fmt.Sprintf("%s/move_effects/data/move_effects.json", DataPath))
+		if err == nil {
+			err = json.Unmarshal(data, &moveEffects)
+		}
+		if err != nil {
+			fmt.Printf("Error loading move effects: %v\n", err)
+		}
+	})
+	effect, ok := moveEffects[move.Identifier]
+	return effect, ok
+}
+
+// applyMoveEffect applies a move's status and stat changes after it has been
+// used, rolling the effect's chance first.
+func (um *UserManager) applyMoveEffect(attacker, defender battler, move *models.Move, result DamageResult) {
+	effect, ok := moveEffect(move)
+	if !ok || result.Missed {
+		return
+	}
+	power, _ := movePower(move)
+	// A damaging move that had no effect on the target has no side effects
+	// either
+	if power > 0 && result.Effectiveness == 0 {
+		return
+	}
+	if effect.Chance > 0 && um.rng.Intn(100) >= effect.Chance {
+		return
+	}
+
+	if effect.Status != "" {
+		um.inflictStatus(defender, effect.Status, power == 0, result)
+	}
+	for _, change := range effect.StatChanges {
+		target := defender
+		if change.Self {
+			target = attacker
+		} else if !target.alive() {
+			continue
+		}
+		um.changeStatStage(target, change.Stat, change.Stages)
+	}
+}
+
+// inflictStatus gives the defending Pokemon a status condition unless it
+// already has one or is immune. Only moves that exist to inflict the status say
+// when it fails.
+func (um *UserManager) inflictStatus(defender battler, status string, statusMove bool, result DamageResult) {
+	target := defender.pokemon()
+	if target.CurrentHP <= 0 {
+		return
+	}
+	if result.Effectiveness == 0 {
+		if statusMove {
+			um.logEvent(effectivenessMessage(result.Effectiveness))
+		}
+		return
+	}
+	if target.Status != "" || statusImmune(target, status) {
+		if statusMove {
+			um.logEvent("But it failed!")
+		}
+		return
+	}
+
+	target.Status = status
+	if status == StatusSleep {
+		target.SleepTurns = 1 + um.rng.Intn(maxSleepTurns)
+	}
+	um.logEvent(fmt.Sprintf("%s %s", defender.name(), statusInflictedMessage(status)))
+}
+
+// canAct checks whether the Pokemon is able to move this turn, waking or
+// thawing it as needed.
+func (um *UserManager) canAct(b battler) bool {
+	pokemon, name := b.pokemon(), b.name()
+
+	switch pokemon.Status {
+	case StatusSleep:
+		if pokemon.SleepTurns > 0 {
+			pokemon.SleepTurns--
+			um.logEvent(fmt.Sprintf("%s is fast asleep.", name))
+			return false
+		}
+		pokemon.Status = ""
+		um.logEvent(fmt.Sprintf("%s woke up!", name))
+	case StatusFreeze:
+		if um.rng.Intn(100) >= thawChance {
+			um.logEvent(fmt.Sprintf("%s is frozen solid!", name))
+			return false
+		}
+		pokemon.Status = ""
+		um.logEvent(fmt.Sprintf("%s thawed out!", name))
+	case StatusParalysis:
+		if um.rng.Intn(100) < paralysisSkipChance {
+			um.logEvent(fmt.Sprintf("%s is paralyzed! It can't move!", name))
+			return false
+		}
+	}
+	return true
+}
+
+// applyStatusDamage hurts a burned or poisoned Pokemon in battle at the end of
+// the round.
+func (um *UserManager) applyStatusDamage(b battler) {
+	if !b.alive() {
+		return
+	}
+	pokemon := b.pokemon()
+	if pokemon.Status != StatusBurn && pokemon.Status != StatusPoison {
+		return
+	}
+
+	damage := pokemon.maxHP() / 8
+	if damage < 1 {
+		damage = 1
+	}
+	pokemon.CurrentHP -= damage
+	if pokemon.CurrentHP < 0 {
+		pokemon.CurrentHP = 0
+	}
+
+	cause := "its burn"
+	if pokemon.Status == StatusPoison {
+		cause = "poison"
+	}
+	um.logEvent(fmt.Sprintf("%s is hurt by %s. Its HP is now %d.", b.name(), cause, pokemon.CurrentHP))
+}
+
+func statusImmune(pokemon *PokemonData, status string) bool {
+	for _, immuneType := range statusImmunities[status] {
+		for _, t := range pokemon.Monster.Types {
+			if t == immuneType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func statusInflictedMessage(status string) string {
+	switch status {
+	case StatusBurn:
+		return "was burned!"
+	case StatusPoison:
+		return "was poisoned!"
+	case StatusParalysis:
+		return "is paralyzed! It may be unable to move!"
+	case StatusSleep:
+		return "fell asleep!"
+	case StatusFreeze:
+		return "was frozen solid!"
+	}
+	return "is affected by " + status
+}
+
+// statusLabel returns the short status tag shown next to a Pokemon's HP.
+func statusLabel(pokemon *PokemonData) string {
+	if label, ok := statusLabels[pokemon.Status]; ok {
+		return " [" + label + "]"
+	}
+	return ""
+}
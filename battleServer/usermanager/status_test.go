@@ -0,0 +1,66 @@
+package usermanager
+
+import (
+	"testing"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+func Test_applyMoveEffect(t *testing.T) {
+	tests := []struct {
+		name       string
+		move       string
+		types      []string
+		status     string
+		wantStatus string
+	}{
+		{name: "thunder wave paralyzes", move: "thunder-wave", types: []string{"water"}, wantStatus: StatusParalysis},
+		{name: "electric types can't be paralyzed", move: "thunder-wave", types: []string{"electric"}},
+		{name: "toxic poisons", move: "toxic", types: []string{"normal"}, wantStatus: StatusPoison},
+		{name: "steel types can't be poisoned", move: "toxic", types: []string{"steel"}},
+		{name: "fire types can't be burned", move: "will-o-wisp", types: []string{"fire"}},
+		{name: "only one status at a time", move: "spore", types: []string{"normal"}, status: StatusBurn, wantStatus: StatusBurn},
+		{name: "moves without effects", move: "tackle", types: []string{"normal"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			um := NewUserManagerWithSeed(1)
+			defender := &User{Username: "defender", Active: []*PokemonData{{
+				Monster:   &models.Monster{Name: "Target", HP: 100, Types: tt.types},
+				CurrentHP: 100,
+				Status:    tt.status,
+			}}}
+			attacker := &User{Username: "attacker", Active: []*PokemonData{{Monster: &models.Monster{Name: "User"}}}}
+			um.applyMoveEffect(battler{user: attacker}, battler{user: defender}, &models.Move{Identifier: tt.move}, DamageResult{Effectiveness: 1})
+			if got := defender.Active[0].Status; got != tt.wantStatus {
+				t.Errorf("status = %q, want %q", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func Test_applyStatusDamage(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		wantHP int
+	}{
+		{name: "burn", status: StatusBurn, wantHP: 70},
+		{name: "poison", status: StatusPoison, wantHP: 70},
+		{name: "paralysis does no damage", status: StatusParalysis, wantHP: 80},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			um := NewUserManagerWithSeed(1)
+			user := &User{Username: "user", Active: []*PokemonData{{
+				Monster:   &models.Monster{Name: "Target", HP: 80},
+				CurrentHP: 80,
+				Status:    tt.status,
+			}}}
+			um.applyStatusDamage(battler{user: user})
+			if got := user.Active[0].CurrentHP; got != tt.wantHP {
+				t.Errorf("HP = %d, want %d", got, tt.wantHP)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package usermanager
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/protocol"
+)
+
+// SwitchPokemon sends in the user's Pokemon at the given 1-based team position.
+// A voluntary switch is the action of the position the user is choosing for;
+// sending in a replacement after a knockout happens straight away.
+func (um *UserManager) SwitchPokemon(username, choice string) {
+	user, exists := um.Users[username]
+	if !exists || !um.BattleStarted {
+		return
+	}
+	position := user.choosing()
+	if position < 0 && !user.AwaitingSwitch {
+		um.sendMessageToUser(user, "You have already chosen an action this round.\n")
+		return
+	}
+
+	index, err := strconv.Atoi(choice)
+	if err != nil || index < 1 || index > len(user.PokemonData) {
+		um.sendMessageToUser(user, fmt.Sprintf("Choose a Pokemon between 1 and %d:\n%s\n", len(user.PokemonData), teamMessage(user)))
+		return
+	}
+	next := user.PokemonData[index-1]
+	if next.CurrentHP <= 0 {
+		um.sendMessageToUser(user, fmt.Sprintf("%s has fainted and cannot battle.\n", next.Monster.Name))
+		return
+	}
+	if !user.switchable(next) {
+		um.sendMessageToUser(user, fmt.Sprintf("%s is already in battle.\n", next.Monster.Name))
+		return
+	}
+
+	if user.AwaitingSwitch {
+		user.sendOut(user.faintedPositions()[0], next)
+		um.broadcastMessage(fmt.Sprintf("%s sent out %s.\n", user.Username, next.Monster.Name))
+		if len(user.faintedPositions()) > 0 {
+			um.sendMessageToUser(user, fmt.Sprintf("Choose a Pokemon to send in with 'switch <n>':\n%s\n", teamMessage(user)))
+			return
+		}
+		user.AwaitingSwitch = false
+		um.startRoundIfReady()
+		return
+	}
+
+	if um.awaitingReplacement() {
+		um.sendMessageToUser(user, "Waiting for your opponent to send in a Pokemon...\n")
+		return
+	}
+	um.submitAction(user, position, &Action{Kind: ActionSwitch, SwitchTo: next})
+}
+
+// replaceKnockedOutPokemon announces the user's knocked out Pokemon and brings
+// in replacements, asking the user to pick them when there is a choice. With
+// nothing left to send in, a position stays empty.
+func (um *UserManager) replaceKnockedOutPokemon(user *User) {
+	fainted := user.faintedPositions()
+	for _, position := range fainted {
+		pokemon := user.Active[position]
+		um.broadcastMessage(fmt.Sprintf("%s's %s has been knocked out.\n", user.Username, pokemon.Monster.Name))
+		um.record(ReplayEvent{Type: ReplayKnockout, Round: um.Round, Username: user.Username, Pokemon: pokemon.Monster.Name})
+	}
+	if len(fainted) == 0 {
+		return
+	}
+
+	bench := user.bench()
+	if len(bench) > len(fainted) {
+		user.AwaitingSwitch = true
+		um.sendMessageToUser(user, fmt.Sprintf("Choose a Pokemon to send in with 'switch <n>':\n%s\n", teamMessage(user)))
+		return
+	}
+	for i, position := range fainted {
+		if i >= len(bench) {
+			user.Active[position] = nil
+			continue
+		}
+		user.sendOut(position, bench[i])
+		um.broadcastMessage(fmt.Sprintf("%s's new active Pokemon is %s.\n", user.Username, bench[i].Monster.Name))
+	}
+}
+
+// teamMessage lists the user's team with each Pokemon's current HP.
+func teamMessage(user *User) string {
+	var lines []string
+	for i, pokemon := range user.PokemonData {
+		status := fmt.Sprintf("%d/%d HP%s", pokemon.CurrentHP, pokemon.maxHP(), statusLabel(pokemon))
+		switch {
+		case pokemon.CurrentHP <= 0:
+			status = "fainted"
+		case user.isActive(pokemon):
+			status += " (active)"
+		}
+		lines = append(lines, fmt.Sprintf("  %d. %s %s", i+1, pokemon.Monster.Name, status))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// targetsMessage lists the opponent's Pokemon in battle by position.
+func targetsMessage(opponent *User) string {
+	var lines []string
+	for _, b := range opponent.battlers() {
+		if !b.alive() {
+			lines = append(lines, fmt.Sprintf("  %d. (empty)", b.position+1))
+			continue
+		}
+		pokemon := b.pokemon()
+		lines = append(lines, fmt.Sprintf("  %d. %s %d/%d HP%s", b.position+1, pokemon.Monster.Name, pokemon.CurrentHP, pokemon.maxHP(), statusLabel(pokemon)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// broadcastMessage sends a message about the battle to both users and any
+// spectators.
+func (um *UserManager) broadcastMessage(message string) {
+	for _, user := range um.Users {
+		um.sendMessageToUser(user, message)
+	}
+	um.sendToSpectators(protocol.StateMessage(message))
+}
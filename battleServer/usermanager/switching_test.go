@@ -0,0 +1,157 @@
+package usermanager
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+// recordConn keeps everything the server writes to a user. Battles write
+// from their own goroutine, so it is safe to use from several.
+type recordConn struct {
+	net.Conn
+	mu   sync.Mutex
+	sent bytes.Buffer
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sent.Write(b)
+}
+
+func (c *recordConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func (c *recordConn) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sent.String()
+}
+
+// newSwitchBattle starts a battle between ash and gary, three Pokemon each.
+func newSwitchBattle() (*UserManager, *recordConn) {
+	um := NewUserManagerWithSeed(1)
+	conns := make(map[string]*recordConn)
+	for _, username := range []string{"ash", "gary"} {
+		conn := &recordConn{}
+		user := &User{Username: username, Conn: conn}
+		for _, name := range []string{"Pikachu", "Bulbasaur", "Squirtle"} {
+			user.PokemonData = append(user.PokemonData, &PokemonData{Monster: &models.Monster{Name: name, HP: 50}})
+		}
+		user.Pokemons = []string{"Pikachu", "Bulbasaur", "Squirtle"}
+		um.Users[username] = user
+		conns[username] = conn
+	}
+	um.StartBattle()
+	conns["ash"].sent.Reset()
+	return um, conns["ash"]
+}
+
+func TestUserManager_SwitchPokemon(t *testing.T) {
+	tests := []struct {
+		name       string
+		first      string
+		choice     string
+		faint      int
+		wantActive string
+		wantText   string
+	}{
+		{name: "switch is the round's action", choice: "2", wantActive: "Bulbasaur", wantText: "ash withdrew Pikachu and sent out Bulbasaur."},
+		{name: "already chosen", first: "2", choice: "3", wantActive: "Bulbasaur", wantText: "You have already chosen an action this round."},
+		{name: "already in battle", choice: "1", wantActive: "Pikachu", wantText: "Pikachu is already in battle."},
+		{name: "fainted", choice: "3", faint: 3, wantActive: "Pikachu", wantText: "Squirtle has fainted and cannot battle."},
+		{name: "out of range", choice: "4", wantActive: "Pikachu", wantText: "Choose a Pokemon between 1 and 3"},
+		{name: "not a number", choice: "Squirtle", wantActive: "Pikachu", wantText: "Choose a Pokemon between 1 and 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			um, conn := newSwitchBattle()
+			ash := um.Users["ash"]
+			if tt.faint > 0 {
+				ash.PokemonData[tt.faint-1].CurrentHP = 0
+			}
+			if tt.first != "" {
+				um.SwitchPokemon("ash", tt.first)
+			}
+			um.SwitchPokemon("ash", tt.choice)
+			if ash.Active[0].Monster.Name != "Pikachu" {
+				t.Errorf("%s was sent in before the round was over", ash.Active[0].Monster.Name)
+			}
+
+			// The switch happens once gary has chosen too
+			um.SwitchPokemon("gary", "2")
+			if got := ash.Active[0].Monster.Name; got != tt.wantActive {
+				t.Errorf("active Pokemon = %s, want %s", got, tt.wantActive)
+			}
+			if !strings.Contains(conn.String(), tt.wantText) {
+				t.Errorf("ash was sent %q, want %q in it", conn.String(), tt.wantText)
+			}
+		})
+	}
+}
+
+func TestUserManager_replaceKnockedOutPokemon(t *testing.T) {
+	tests := []struct {
+		name         string
+		fainted      []int
+		wantActive   string
+		wantAwaiting bool
+		wantText     string
+	}{
+		{name: "last Pokemon is sent in", fainted: []int{1, 2}, wantActive: "Squirtle", wantText: "ash's new active Pokemon is Squirtle."},
+		{name: "choice of Pokemon", fainted: []int{1}, wantActive: "Pikachu", wantAwaiting: true, wantText: "Choose a Pokemon to send in with 'switch <n>'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			um, conn := newSwitchBattle()
+			ash := um.Users["ash"]
+			for _, i := range tt.fainted {
+				ash.PokemonData[i-1].CurrentHP = 0
+			}
+			um.replaceKnockedOutPokemon(ash)
+			if got := ash.Active[0].Monster.Name; got != tt.wantActive {
+				t.Errorf("active Pokemon = %s, want %s", got, tt.wantActive)
+			}
+			if ash.AwaitingSwitch != tt.wantAwaiting {
+				t.Errorf("AwaitingSwitch = %v, want %v", ash.AwaitingSwitch, tt.wantAwaiting)
+			}
+			if !strings.Contains(conn.String(), tt.wantText) {
+				t.Errorf("ash was sent %q, want %q in it", conn.String(), tt.wantText)
+			}
+		})
+	}
+}
+
+func TestUserManager_forcedSwitch(t *testing.T) {
+	// A Pokemon sent in after a knockout comes in straight away, and no move
+	// is taken until one is
+	um, conn := newSwitchBattle()
+	ash := um.Users["ash"]
+	ash.PokemonData[0].CurrentHP = 0
+	um.replaceKnockedOutPokemon(ash)
+
+	um.PerformBattle("1", "ash")
+	if !strings.Contains(conn.String(), "Send in a Pokemon first") {
+		t.Errorf("a move before sending in a Pokemon was not refused: %q", conn.String())
+	}
+	um.SwitchPokemon("ash", "1")
+	if ash.Active[0].Monster.Name != "Pikachu" || !ash.AwaitingSwitch {
+		t.Errorf("the fainted Pokemon was sent back in")
+	}
+	um.SwitchPokemon("ash", "3")
+	if ash.Active[0].Monster.Name != "Squirtle" || ash.AwaitingSwitch {
+		t.Errorf("active Pokemon = %s, AwaitingSwitch = %v, want Squirtle sent in", ash.Active[0].Monster.Name, ash.AwaitingSwitch)
+	}
+	for _, want := range []string{"ash sent out Squirtle.", "Squirtle is at 50/50 HP. Choose your next move"} {
+		if !strings.Contains(conn.String(), want) {
+			t.Errorf("ash was sent %q, want %q in it", conn.String(), want)
+		}
+	}
+}
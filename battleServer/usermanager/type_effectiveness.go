@@ -0,0 +1,69 @@
+package usermanager
+
+import "strconv"
+
+const stabMultiplier = 1.5
+
+// typeEffectiveness returns the combined multiplier of a move of the given type
+// against every type of the defending Pokemon. Dual types stack, so a 2x and a
+// 2x weakness give 4x, and any 0x immunity wins outright.
+func typeEffectiveness(moveType string, defender *PokemonData) float64 {
+	multiplier := 1.0
+	for _, t := range defender.Types {
+		if t == nil {
+			continue
+		}
+		for _, when := range t.WhenDefending {
+			if when.Name == moveType {
+				multiplier *= multiplierValue(when.Multiplier)
+			}
+		}
+	}
+	return multiplier
+}
+
+// sameTypeAttackBonus returns the STAB multiplier when the move shares a type
+// with the attacking Pokemon. Pokemon caught in pokeCat bring their own
+// elemental multiplier.
+func sameTypeAttackBonus(moveType string, attacker *PokemonData) float64 {
+	for _, t := range attacker.Monster.Types {
+		if t != moveType {
+			continue
+		}
+		if attacker.Caught != nil && attacker.Caught.ElementalMultiplier > 0 {
+			return 1 + attacker.Caught.ElementalMultiplier
+		}
+		return stabMultiplier
+	}
+	return 1.0
+}
+
+// effectivenessMessage describes a type multiplier the way the games do.
+func effectivenessMessage(multiplier float64) string {
+	switch {
+	case multiplier == 0:
+		return "It had no effect..."
+	case multiplier > 1:
+		return "It's super effective!"
+	case multiplier < 1:
+		return "It's not very effective..."
+	}
+	return ""
+}
+
+// multiplierValue converts the loosely typed multiplier from the type data
+// files into a float64, defaulting to neutral when it can't be read.
+func multiplierValue(v interface{}) float64 {
+	switch m := v.(type) {
+	case float64:
+		return m
+	case int:
+		return float64(m)
+	case string:
+		f, err := strconv.ParseFloat(m, 64)
+		if err == nil {
+			return f
+		}
+	}
+	return 1.0
+}
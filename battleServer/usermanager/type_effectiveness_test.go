@@ -0,0 +1,51 @@
+package usermanager
+
+import (
+	"testing"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+func Test_typeEffectiveness(t *testing.T) {
+	flying := &models.Types{WhenDefending: []models.When{{Multiplier: 0.0, Name: "ground"}, {Multiplier: 2.0, Name: "electric"}, {Multiplier: 2.0, Name: "ice"}}}
+	water := &models.Types{WhenDefending: []models.When{{Multiplier: 2.0, Name: "electric"}, {Multiplier: 0.5, Name: "ice"}}}
+	gyarados := &PokemonData{Monster: &models.Monster{Types: []string{"water", "flying"}}, Types: []*models.Types{water, flying}}
+
+	tests := []struct {
+		name     string
+		moveType string
+		want     float64
+	}{
+		{name: "dual weakness stacks", moveType: "electric", want: 4},
+		{name: "weakness and resistance cancel", moveType: "ice", want: 1},
+		{name: "immunity", moveType: "ground", want: 0},
+		{name: "neutral", moveType: "normal", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := typeEffectiveness(tt.moveType, gyarados); got != tt.want {
+				t.Errorf("typeEffectiveness() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_sameTypeAttackBonus(t *testing.T) {
+	charizard := &PokemonData{Monster: &models.Monster{Types: []string{"flying", "fire"}}}
+
+	tests := []struct {
+		name     string
+		moveType string
+		want     float64
+	}{
+		{name: "matching type", moveType: "fire", want: stabMultiplier},
+		{name: "other type", moveType: "dragon", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameTypeAttackBonus(tt.moveType, charizard); got != tt.want {
+				t.Errorf("sameTypeAttackBonus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,23 +3,36 @@ package usermanager
 import (
 	"encoding/json"
 	"fmt"
-	"math"
+	"io"
 	"math/rand"
 	"net"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/nguyensngoc108/pokemon-game/internal/models"
+	"github.com/nguyensngoc108/pokemon-game/internal/protocol"
 	"github.com/nguyensngoc108/pokemon-game/utils"
 )
 
 type User struct {
-	Username      string
-	Pokemons      []string
-	Conn          net.Conn
-	PokemonData   []*PokemonData
-	ActivePokemon *PokemonData
-	ActiveHP      int
+	Username    string
+	Pokemons    []string
+	Conn        net.Conn
+	PokemonData []*PokemonData
+	// Active holds the Pokemon in battle, one per position of the format. A
+	// position is left empty once its Pokemon faints with none to replace it.
+	Active []*PokemonData
+	// AwaitingSwitch is set while the user has to send in a new Pokemon after
+	// a knockout.
+	AwaitingSwitch bool
+	// PendingActions holds the action chosen for each position this round.
+	PendingActions []*Action
+	// Bot is set when the server plays this user's side of the battle. Bots
+	// have no connection.
+	Bot *Bot
 }
 
 type PokemonData struct {
@@ -29,38 +42,101 @@ type PokemonData struct {
 	Types               []*models.Types             `json:"types"`
 	MonsterSupplemental *models.MonsterSupplemental `json:"monster_supplemental"`
 	MonsterMoves        []*models.Move              `json:"monster_moves"`
+	Level               int                         `json:"level,omitempty"`
+	Moveset             []*MoveSlot                 `json:"-"`
+	CurrentHP           int                         `json:"-"`
+	// Status is the Pokemon's major status condition, if any.
+	Status string `json:"-"`
+	// SleepTurns counts the turns left before a sleeping Pokemon wakes up.
+	SleepTurns int `json:"-"`
+	// StatStages holds the stages, -6 to +6, that moves have raised or
+	// lowered the Pokemon's stats by since it was sent out.
+	StatStages map[string]int `json:"-"`
+	// Revealed is set once the Pokemon has been sent out, so spectators
+	// may see it.
+	Revealed bool `json:"-"`
+	// Caught is the pokeCat individual the Pokemon was built from, if any.
+	Caught *CaughtPokemon `json:"-"`
+	// Instance is the individual the Pokemon is: its IVs, EVs and nature
+	// decide its stats along with its species and level.
+	Instance *models.Instance `json:"-"`
+	// EVsEarned and ExperienceEarned add up what the Pokemon earned for
+	// knocking out opponents this battle. They are awarded when the battle
+	// is over.
+	EVsEarned        models.Stats `json:"-"`
+	ExperienceEarned int          `json:"-"`
 }
 
+// DataPath is the location of the Pokemon data files, relative to the
+// directory the server is started from.
+var DataPath = "../internal/models"
+
+// writeTimeout bounds how long a message to one user may block, so a client
+// that stops reading can't stall the lobby or a battle.
+const writeTimeout = 5 * time.Second
+
+// UserManager is a single battle between two users. Once Run has been called
+// its state belongs to the goroutine running it, and other goroutines talk to
+// the battle only through Submit.
 type UserManager struct {
 	Users         map[string]*User
-	CurrentTurn   string
+	Round         int
 	BattleStarted bool
-}
-
-var userManagerInstance *UserManager
-
-func GetUserManagerInstance() *UserManager {
-	return userManagerInstance
-}
-
-func SetUserManagerInstance(um *UserManager) *UserManager {
-	userManagerInstance = um
-	return userManagerInstance
+	// Positions is how many Pokemon each user has in battle at once: 1 for
+	// singles, 2 for doubles. Zero means 1.
+	Positions int
+	// Winner is the username of the winner once the battle is over.
+	Winner string
+	// KeepHP starts Pokemon with the HP their individual has rather than
+	// fully healed, and leaves the individual with the HP it ends the battle
+	// on, as Pokemon in the pokeCat world keep their HP between battles. One
+	// with no HP, having fainted or never been hurt, starts fully healed.
+	KeepHP bool
+	// OnFinish is called from the battle's goroutine once it is over.
+	OnFinish func(um *UserManager)
+	// ReconnectGrace is how long a disconnected user has to come back
+	// before forfeiting.
+	ReconnectGrace time.Duration
+	// ReplayLog receives the battle's replay log as JSON lines when set.
+	ReplayLog     io.Writer
+	seed          int64
+	rng           *rand.Rand
+	roundEvents   []string
+	commands      chan Command
+	done          chan struct{}
+	transcripts   map[string][]protocol.Message
+	disconnected  map[string]bool
+	forfeitTimers map[string]*time.Timer
+	spectators    map[string]*spectator
 }
 
 func (um *UserManager) GetUserPokemon(username string) string {
-	return um.Users[username].ActivePokemon.Monster.Name
+	return um.Users[username].Active[0].Monster.Name
 }
 func (um *UserManager) GetUserPokemonHP(username string) int {
-	return um.Users[username].ActivePokemon.Monster.HP
+	return um.Users[username].Active[0].maxHP()
 }
 func (um *UserManager) GetUserPokemonActiveHP(username string) int {
-	return um.Users[username].ActiveHP
+	return um.Users[username].Active[0].CurrentHP
 }
 
 func NewUserManager() *UserManager {
+	return NewUserManagerWithSeed(time.Now().UnixNano())
+}
+
+// NewUserManagerWithSeed creates a UserManager whose battle rolls are driven by
+// the given seed, so the same inputs always play out the same way.
+func NewUserManagerWithSeed(seed int64) *UserManager {
 	return &UserManager{
-		Users: make(map[string]*User),
+		Users:         make(map[string]*User),
+		seed:          seed,
+		rng:           rand.New(rand.NewSource(seed)),
+		commands:      make(chan Command, 16),
+		done:          make(chan struct{}),
+		transcripts:   make(map[string][]protocol.Message),
+		disconnected:  make(map[string]bool),
+		forfeitTimers: make(map[string]*time.Timer),
+		spectators:    make(map[string]*spectator),
 	}
 }
 
@@ -68,7 +144,7 @@ func (um *UserManager) AddUser(username string, conn net.Conn) *User {
 	if _, exists := um.Users[username]; !exists {
 		user := &User{
 			Username: username,
-			Pokemons: make([]string, 3),
+			Pokemons: make([]string, Formats[DefaultFormat].TeamSize),
 			Conn:     conn,
 		}
 		um.Users[username] = user
@@ -77,11 +153,38 @@ func (um *UserManager) AddUser(username string, conn net.Conn) *User {
 	return um.Users[username]
 }
 
+// JoinUser adds a user that already has a team, such as one coming from the
+// lobby, to the battle.
+func (um *UserManager) JoinUser(user *User) {
+	um.Users[user.Username] = user
+}
+
 func (um *UserManager) UpdatePokemons(username, pokemon string, index int) {
 	user, exists := um.Users[username]
 	if exists {
-		user.Pokemons[index-1] = pokemon
+		user.UpdatePokemons(pokemon, index)
+	}
+}
+
+func (u *User) UpdatePokemons(pokemon string, index int) {
+	if index < 1 || index > len(u.Pokemons) {
+		return
 	}
+	u.Pokemons[index-1] = pokemon
+}
+
+// TeamComplete reports whether the user has provided every Pokemon of their
+// team.
+func (u *User) TeamComplete() bool {
+	if len(u.Pokemons) == 0 || len(u.PokemonData) != len(u.Pokemons) {
+		return false
+	}
+	for _, pokemon := range u.Pokemons {
+		if pokemon == "" {
+			return false
+		}
+	}
+	return true
 }
 
 func (um *UserManager) GetOpponentPokemons(username string) []string {
@@ -99,16 +202,11 @@ func (um *UserManager) AllPokemonsProvided() bool {
 		return false
 	}
 
-	// Check if each player has provided 3 Pokemon
+	// Check if each player has provided their whole team
 	for _, user := range um.Users {
-		if len(user.Pokemons) != 3 {
+		if !user.TeamComplete() {
 			return false
 		}
-		for _, pokemon := range user.Pokemons {
-			if pokemon == "" {
-				return false
-			}
-		}
 	}
 	return true
 }
@@ -117,31 +215,69 @@ func (um *UserManager) StartBattle() {
 	// Broadcast the Pokemon information to both players
 	um.broadcastPokemons()
 
-	// Determine the player who goes first based on the speed of their first Pokemon
-	um.determineTurnOrder()
-
-	// Set initial Pokemon and HP
+	// Set initial Pokemon, HP and PP
 	for _, user := range um.Users {
-		user.ActivePokemon = user.PokemonData[0]
-		user.ActiveHP = user.ActivePokemon.Monster.HP
+		for _, pokemon := range user.PokemonData {
+			pokemon.CurrentHP = pokemon.maxHP()
+			if um.KeepHP && pokemon.Instance != nil && pokemon.Instance.CurrentHP > 0 {
+				pokemon.CurrentHP = min(pokemon.Instance.CurrentHP, pokemon.CurrentHP)
+			}
+			pokemon.EVsEarned, pokemon.ExperienceEarned = models.Stats{}, 0
+			pokemon.Status, pokemon.SleepTurns = "", 0
+			pokemon.StatStages = nil
+			pokemon.Revealed = false
+			for _, slot := range pokemon.Moveset {
+				slot.PP = slot.MaxPP
+			}
+		}
+		// A team smaller than the format's positions fills what it can
+		positions := um.positions()
+		if positions > len(user.PokemonData) {
+			positions = len(user.PokemonData)
+		}
+		user.Active = make([]*PokemonData, positions)
+		for position := range user.Active {
+			user.sendOut(position, user.PokemonData[position])
+		}
+		user.AwaitingSwitch = false
+		user.PendingActions = make([]*Action, positions)
 	}
-	currentUser := um.Users[um.CurrentTurn]
-	message := fmt.Sprintf("\n %s is at %d/%d HP. Choose your next move (type in 'normal' or 'special'): ", currentUser.ActivePokemon.Monster.Name, currentUser.ActiveHP, currentUser.ActivePokemon.Monster.HP)
-	um.sendMessageToUser(currentUser, message)
-}
+	um.BattleStarted = true
 
-func (um *UserManager) determineTurnOrder() {
-	// Determine the player who goes first based on the speed of their first Pokemon
-	user1 := um.Users[um.getPlayerNames()[0]]
-	user2 := um.Users[um.getPlayerNames()[1]]
+	um.recordStart()
 
-	if user1.PokemonData[0].Monster.Speed > user2.PokemonData[0].Monster.Speed {
-		um.CurrentTurn = user1.Username
-	} else {
-		um.CurrentTurn = user2.Username
+	// Both players choose their actions for the first round
+	um.startRound()
+}
+
+// promptForMove sends the HP and moveset of the Pokemon the user chooses an
+// action for next, along with their team. In doubles the targets are listed
+// too.
+func (um *UserManager) promptForMove(user *User) {
+	position := user.choosing()
+	if position < 0 {
+		return
+	}
+	pokemon := user.Active[position]
+	name, targets := pokemon.Monster.Name, ""
+	if len(user.Active) > 1 {
+		name = fmt.Sprintf("%s (position %d)", name, position+1)
+		targets = " followed by the target's position"
 	}
+	message := fmt.Sprintf("\n %s is at %d/%d HP%s%s. Choose your next move by number or name%s, 'switch <n>' or 'quit':\n%s\n", name, pokemon.CurrentHP, pokemon.maxHP(), statusLabel(pokemon), statStagesLabel(pokemon), targets, movesetMessage(pokemon))
+	if targets != "" {
+		message += fmt.Sprintf("Targets:\n%s\n", targetsMessage(um.getOpponent(user.Username)))
+	}
+	message += fmt.Sprintf("Team:\n%s\n", teamMessage(user))
+	um.sendMessageToUser(user, message)
+}
 
-	um.BattleStarted = true
+// positions returns how many Pokemon each user has in battle at once.
+func (um *UserManager) positions() int {
+	if um.Positions < 1 {
+		return 1
+	}
+	return um.Positions
 }
 
 func (um *UserManager) getPlayerNames() []string {
@@ -149,21 +285,16 @@ func (um *UserManager) getPlayerNames() []string {
 	for username := range um.Users {
 		playerNames = append(playerNames, username)
 	}
+	// Keep a stable order so seeded battles always play out the same way
+	sort.Strings(playerNames)
 	return playerNames
 }
 
 func (um *UserManager) broadcastPokemons() {
 	for _, user := range um.Users {
 		message := fmt.Sprintf("%s's Pokemon: %s\nOpponent's Pokemon: %s", user.Username, strings.Join(user.Pokemons, ", "), strings.Join(um.getOpponentPokemons(user), ", "))
-		_, err := user.Conn.Write([]byte(message))
-		if err != nil {
-			fmt.Printf("Error sending message to %s: %v\n", user.Username, err)
-		}
-		message = fmt.Sprintf("Battle Commence")
-		_, err = user.Conn.Write([]byte(message))
-		if err != nil {
-			fmt.Printf("Error sending message to %s: %v\n", user.Username, err)
-		}
+		um.sendMessageToUser(user, message)
+		um.sendMessageToUser(user, "Battle Commence")
 	}
 }
 
@@ -176,112 +307,130 @@ func (um *UserManager) getOpponentPokemons(currentUser *User) []string {
 	return nil
 }
 
-func (um *UserManager) PerformBattle(moveType string, username string) {
-	currentUser := um.Users[um.CurrentTurn]
+// PerformBattle records the move a user has chosen for their next position
+// this round. In doubles the move may be followed by the position of the
+// opponent's Pokemon to target, e.g. "thunderbolt 2".
+func (um *UserManager) PerformBattle(choice string, username string) {
+	currentUser, exists := um.Users[username]
+	if !exists || !um.BattleStarted {
+		return
+	}
 	opponent := um.getOpponent(currentUser.Username)
-	//message := fmt.Sprintf("\n %s is at %d/%d HP. Choose your next move (type in 'normal' or 'special'): ", currentUser.ActivePokemon.Monster.Name, currentUser.ActiveHP, currentUser.ActivePokemon.Monster.HP)
-	//um.sendMessageToUser(currentUser, message)
 
-	if username != currentUser.Username {
+	if choice == "quit" {
+		um.announceWinner(opponent.Username)
 		return
 	}
 
-	// Verify that the move type is valid (either "normal attack" or "special attack")
-	if moveType != "normal" && moveType != "special" && moveType != "quit" {
-		um.sendMessageToUser(currentUser, fmt.Sprintf("Invalid move type: %s", moveType))
+	if currentUser.AwaitingSwitch {
+		um.sendMessageToUser(currentUser, fmt.Sprintf("Send in a Pokemon first with 'switch <n>':\n%s\n", teamMessage(currentUser)))
 		return
 	}
-	if moveType == "quit" {
-		um.announceWinner(opponent.Username)
+	position := currentUser.choosing()
+	if position < 0 {
+		um.sendMessageToUser(currentUser, "You have already chosen an action this round.\n")
+		return
+	}
+	if um.awaitingReplacement() {
+		um.sendMessageToUser(currentUser, "Waiting for your opponent to send in a Pokemon...\n")
+		return
+	}
+	choice, target, err := splitTarget(choice, len(opponent.Active))
+	if err != nil {
+		um.sendMessageToUser(currentUser, fmt.Sprintf("Invalid target: %v\n", err))
 		return
 	}
-	// Select a random move of the chosen type
-	choosenMove := um.selectRandomMove(currentUser, moveType)
-
-	// Calculate and apply the damage
-	um.calculateAndApplyDamage(currentUser, opponent, choosenMove)
 
-	// Check if the opponent's active Pokemon is knocked out
-	if opponent.ActiveHP <= 0 {
-		if um.hasLost(opponent) {
-			um.announceWinner(currentUser.Username)
+	// Resolve the chosen move, falling back to Struggle once every move is
+	// out of PP
+	pokemon := currentUser.Active[position]
+	if !hasPPLeft(pokemon) {
+		struggle, err := readStruggle()
+		if err != nil {
+			fmt.Printf("Error loading Struggle: %v\n", err)
 			return
 		}
-		um.replaceKnockedOutPokemon(opponent)
+		um.submitAction(currentUser, position, &Action{Kind: ActionMove, Move: struggle, Target: target})
+		return
 	}
-
-	// Switch the turn to the other player
-	um.switchTurn()
-	message := fmt.Sprintf("\n %s is at %d/%d HP. Choose your next move (type in 'normal' or 'special' or 'quit'): ", opponent.ActivePokemon.Monster.Name, opponent.ActiveHP, opponent.ActivePokemon.Monster.HP)
-	um.sendMessageToUser(opponent, message)
-}
-
-func (um *UserManager) selectRandomMove(user *User, moveType string) string {
-	// Filter the moves that match the specified type
-	var matchingMoves []*models.Move
-	if moveType == "special" {
-		for _, move := range user.ActivePokemon.MonsterMoves {
-			if move.TypeName != "normal" && move.Power != "" {
-				matchingMoves = append(matchingMoves, move)
-			}
-		}
-	} else {
-		for _, move := range user.ActivePokemon.MonsterMoves {
-			if move.TypeName == moveType {
-				matchingMoves = append(matchingMoves, move)
-			}
-		}
+	slot, err := selectMove(pokemon, choice)
+	if err != nil {
+		um.sendMessageToUser(currentUser, fmt.Sprintf("Invalid move: %v\n", err))
+		return
 	}
-
-	// If there are no matching moves, return an empty string
-	if len(matchingMoves) == 0 {
-		return ""
+	if slot.PP <= 0 {
+		um.sendMessageToUser(currentUser, fmt.Sprintf("%s has no PP left. Choose another move.\n", slot.Move.Name))
+		return
 	}
-
-	// Select a random move from the matching moves
-	randomIndex := rand.Intn(len(matchingMoves))
-	selectedMove := matchingMoves[randomIndex]
-	return selectedMove.Name
+	um.submitAction(currentUser, position, &Action{Kind: ActionMove, Slot: slot, Move: slot.Move, Target: target})
 }
 
-func (um *UserManager) calculateAndApplyDamage(currentUser, defender *User, moveName string) {
-	// Find the move object based on the move name
-	var attackingMove *models.Move
-	for _, move := range currentUser.ActivePokemon.MonsterMoves {
-		if move.Name == moveName {
-			attackingMove = move
-			break
-		}
+// splitTarget splits the 0-based target position off the end of a move
+// choice. Without one the move targets the first position.
+func splitTarget(choice string, positions int) (string, int, error) {
+	fields := strings.Fields(choice)
+	if len(fields) < 2 {
+		return choice, 0, nil
 	}
-
-	if attackingMove == nil {
-		um.sendMessageToUser(currentUser, fmt.Sprintf("%s does not have the move %s", currentUser.ActivePokemon.Monster.Name, moveName))
-		return
+	target, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return choice, 0, nil
 	}
+	if target < 1 || target > positions {
+		return "", 0, fmt.Errorf("choose a target between 1 and %d", positions)
+	}
+	return strings.Join(fields[:len(fields)-1], " "), target - 1, nil
+}
 
-	// Calculate the damage based on the move type
-	var damage int
-	if attackingMove.TypeName == "normal" {
-		damage = int(math.Abs(float64(currentUser.ActivePokemon.Monster.Attack - defender.ActivePokemon.Monster.Defense)))
-	} else {
-		damage = int(math.Abs(float64(currentUser.ActivePokemon.Monster.SpAtk - defender.ActivePokemon.Monster.SpDef)))
+func (um *UserManager) calculateAndApplyDamage(attacker, defender battler, attackingMove *models.Move) DamageResult {
+	target := defender.pokemon()
+	// Roll accuracy, critical hit and damage for the move
+	result := calculateDamage(um.rng, attacker.pokemon(), target, attackingMove)
+	if result.Missed {
+		um.record(ReplayEvent{Type: ReplayDamage, Round: um.Round, Username: attacker.user.Username, Target: defender.user.Username, Move: attackingMove.Identifier, HP: target.CurrentHP, Missed: true})
+		um.logEvent(fmt.Sprintf("%s used %s, but it missed!", attacker.name(), attackingMove.Name))
+		return result
+	}
+	// Status moves do no damage; their effect is reported separately
+	if power, _ := movePower(attackingMove); power == 0 {
+		um.logEvent(fmt.Sprintf("%s used %s!", attacker.name(), attackingMove.Name))
+		return result
 	}
 
 	// Apply the damage to the defender's HP
-	defender.ActiveHP = defender.ActiveHP - damage
-	if defender.ActiveHP < 0 {
-		defender.ActiveHP = 0
+	target.CurrentHP = target.CurrentHP - result.Damage
+	if target.CurrentHP <= 0 {
+		target.CurrentHP = 0
+		earnRewards(attacker.pokemon(), target)
 	}
 
+	um.record(ReplayEvent{Type: ReplayDamage, Round: um.Round, Username: attacker.user.Username, Target: defender.user.Username, Move: attackingMove.Identifier, Damage: result.Damage, HP: target.CurrentHP, Critical: result.Critical})
+
 	// Send the damage update to both players
-	um.sendMessageToUser(currentUser, fmt.Sprintf("%s's %s did %d damage to %s's %s. %s's HP is now %d.", currentUser.Username, attackingMove.Name, damage, defender.Username, defender.ActivePokemon.Monster.Name, defender.Username, defender.ActiveHP))
-	um.sendMessageToUser(defender, fmt.Sprintf("%s's %s did %d damage to %s's %s. %s's HP is now %d.", currentUser.Username, attackingMove.Name, damage, defender.Username, defender.ActivePokemon.Monster.Name, defender.Username, defender.ActiveHP))
+	message := fmt.Sprintf("%s's %s did %d damage to %s. %s's HP is now %d.", attacker.user.Username, attackingMove.Name, result.Damage, defender.name(), defender.user.Username, target.CurrentHP)
+	if result.Critical {
+		message = fmt.Sprintf("%s A critical hit!", message)
+	}
+	if effect := effectivenessMessage(result.Effectiveness); effect != "" {
+		message = fmt.Sprintf("%s %s", message, effect)
+	}
+	um.logEvent(message)
+	return result
 }
 
 func (um *UserManager) sendMessageToUser(user *User, message string) {
-	_, err := user.Conn.Write([]byte(message))
-	if err != nil {
-		fmt.Printf("Error sending message to %s: %v\n", user.Username, err)
+	um.sendToUser(user, protocol.StateMessage(message))
+}
+
+// send writes a protocol message to the user's connection. Users without one,
+// such as bots, are skipped.
+func (u *User) send(msg protocol.Message) {
+	if u.Conn == nil {
+		return
+	}
+	u.Conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := protocol.Write(u.Conn, msg); err != nil {
+		fmt.Printf("Error sending message to %s: %v\n", u.Username, err)
 	}
 }
 
@@ -294,88 +443,127 @@ func (um *UserManager) getOpponent(username string) *User {
 	return nil
 }
 
-func (um *UserManager) replaceKnockedOutPokemon(user *User) {
-	// Find the index of the knocked out Pokemon in the user's Pokemon list
-	var knockedOutIndex int
-	for i, pokemon := range user.Pokemons {
-		if pokemon == user.ActivePokemon.Monster.Name {
-			knockedOutIndex = i
-			break
+func (um *UserManager) hasLost(user *User) bool {
+	for _, pokemon := range user.PokemonData {
+		if pokemon.CurrentHP > 0 {
+			return false
 		}
 	}
+	return true
+}
 
-	// Select the next available Pokemon
-	var nextPokemonIndex int
-	for i, _ := range user.Pokemons {
-		if i > knockedOutIndex && knockedOutIndex != 2 {
-			nextPokemonIndex = i
-			break
+func (um *UserManager) announceWinner(winner string) {
+	um.BattleStarted = false
+	um.Winner = winner
+	um.record(ReplayEvent{Type: ReplayResult, Round: um.Round, Winner: winner})
+
+	// Announce the winner of the battle to both players
+	for _, user := range um.Users {
+		um.sendToUser(user, protocol.Message{Type: protocol.TypeResult, Winner: winner, Text: fmt.Sprintf("The winner is %s!", winner)})
+	}
+	um.sendToSpectators(protocol.Message{Type: protocol.TypeResult, Winner: winner, Text: fmt.Sprintf("The winner is %s!", winner)})
+	if um.KeepHP {
+		for _, user := range um.Users {
+			for _, pokemon := range user.PokemonData {
+				if pokemon.Instance != nil {
+					pokemon.Instance.CurrentHP = pokemon.CurrentHP
+				}
+			}
 		}
 	}
+	um.awardRewards()
+}
 
-	// Update the user's active Pokemon and HP
-	user.ActivePokemon = user.PokemonData[nextPokemonIndex]
-	user.ActiveHP = user.ActivePokemon.Monster.HP
-	// Check if the user has no more Pokemon left
-	if knockedOutIndex == 2 {
-		um.announceWinner(um.getOpponent(user.Username).Username)
+func (um *UserManager) UpdatePokemonData(username, pokemonName string, pokemonIndex int, moves []string) error {
+	user, exists := um.Users[username]
+	if !exists {
+		return fmt.Errorf("user %s not found", username)
 	}
-	// Send a message to the user about the Pokemon change
-	um.sendMessageToUser(user, fmt.Sprintf("%s's %s has been knocked out. %s's new active Pokemon is %s.", user.Username, user.Pokemons[knockedOutIndex], user.Username, user.Pokemons[nextPokemonIndex]))
-
+	return user.UpdatePokemonData(pokemonName, pokemonIndex, moves)
 }
 
-func (um *UserManager) switchTurn() {
-	// Switch the current turn to the other player
-	playerNames := um.getPlayerNames()
-	for i, name := range playerNames {
-		if name == um.CurrentTurn {
-			um.CurrentTurn = playerNames[(i+1)%len(playerNames)]
-			return
-		}
+// UpdatePokemonData loads the named Pokemon into the given 1-based position of
+// the user's team.
+func (u *User) UpdatePokemonData(pokemonName string, pokemonIndex int, moves []string) error {
+	pokemonData, err := loadPokemon(protocol.TeamMember{Name: pokemonName, Moves: moves})
+	if err != nil {
+		return err
 	}
+	return u.setPokemonData(pokemonData, pokemonIndex)
 }
 
-func (um *UserManager) hasLost(user *User) bool {
-	if user.ActiveHP == 0 && user.ActivePokemon == user.PokemonData[2] {
-		return true
+// UpdateTeamMember loads a team member into the given 1-based position of the
+// user's team and records its name.
+func (u *User) UpdateTeamMember(index int, member protocol.TeamMember) error {
+	if index < 1 || index > len(u.Pokemons) {
+		return fmt.Errorf("position %d is outside a team of %d", index, len(u.Pokemons))
+	}
+	pokemonData, err := loadPokemon(member)
+	if err != nil {
+		return err
+	}
+	if err := u.setPokemonData(pokemonData, index); err != nil {
+		return err
 	}
-	return false
+	u.Pokemons[index-1] = pokemonData.Monster.Name
+	return nil
 }
 
-func (um *UserManager) announceWinner(winner string) {
-	// Announce the winner of the battle to both players
-	for _, user := range um.Users {
-		_, err := user.Conn.Write([]byte(fmt.Sprintf("The winner is %s!", winner)))
-		if err != nil {
-			fmt.Printf("Error sending message to %s: %v\n", user.Username, err)
-		}
+func (u *User) setPokemonData(pokemonData *PokemonData, pokemonIndex int) error {
+	if pokemonIndex < 1 || pokemonIndex > len(u.PokemonData)+1 {
+		return fmt.Errorf("Pokemon %d can't be added before Pokemon %d", pokemonIndex, len(u.PokemonData)+1)
+	}
+	if pokemonIndex > len(u.PokemonData) {
+		u.PokemonData = append(u.PokemonData, pokemonData)
+	} else {
+		u.PokemonData[pokemonIndex-1] = pokemonData
 	}
+	return nil
 }
 
-func (um *UserManager) UpdatePokemonData(username, pokemonName string, pokemonIndex int) error {
-	user, exists := um.Users[username]
-	if !exists {
-		return fmt.Errorf("user %s not found", username)
+// loadPokemon reads a team member's species data at its level and picks the
+// moves it battles with.
+func loadPokemon(member protocol.TeamMember) (*PokemonData, error) {
+	pokemonID, ok := speciesID(member.Name)
+	if !ok {
+		return nil, fmt.Errorf("unknown Pokemon %q", member.Name)
 	}
-	pokemonID := utils.PokeMap[pokemonName]
-
-	// Construct the path to the Pokemon data file relative to the current file
-	//pokemonDataFilePath := filepath.Join("..", "internal", "models", "monsters", "data", fmt.Sprintf("%d.json", pokemonID))
-	pokemonDataFilePath := fmt.Sprintf("../internal/models/monsters/data/%s.json", pokemonID)
+	pokemonDataFilePath := fmt.Sprintf("%s/monsters/data/%s.json", DataPath, pokemonID)
 	pokemonData, err := readPokemonJSONData(pokemonDataFilePath)
 	if err != nil {
-		return fmt.Errorf("error reading Pokemon data: %v", err)
+		return nil, fmt.Errorf("error reading Pokemon data: %v", err)
+	}
+	if member.Level < 0 {
+		return nil, fmt.Errorf("%s can't be level %d", pokemonData.Monster.Name, member.Level)
+	}
+	if member.Level > 0 {
+		pokemonData.Level = member.Level
+	}
+	pokemonData.Instance = &models.Instance{
+		SpeciesID: pokemonData.Monster.NationalID,
+		Level:     pokemonLevel(pokemonData),
+		IVs:       perfectIVs,
 	}
 
-	// Update the user's Pokemon information
-	if len(user.PokemonData) < pokemonIndex {
-		user.PokemonData = append(user.PokemonData, pokemonData)
-	} else {
-		user.PokemonData[pokemonIndex-1] = pokemonData
+	// Pick the moves the Pokemon battles with
+	pokemonData.Moveset, err = buildMoveset(pokemonData, member.Moves)
+	if err != nil {
+		return nil, err
 	}
+	return pokemonData, nil
+}
 
-	return nil
+// speciesID looks up a species' id by name, ignoring case.
+func speciesID(name string) (string, bool) {
+	if id, ok := utils.PokeMap[name]; ok {
+		return id, true
+	}
+	for species, id := range utils.PokeMap {
+		if strings.EqualFold(species, name) {
+			return id, true
+		}
+	}
+	return "", false
 }
 
 //	func getPokemonIDFromName(pokemonName string) int {
@@ -0,0 +1,17 @@
+package usermanager
+
+// NewPokemonUser creates a user whose team is a single individual Pokemon,
+// such as one from the pokeCat world. It battles with its own level, IVs,
+// EVs, nature and moves, and like a caught Pokemon it is rewarded with EVs
+// and experience after the battle.
+func NewPokemonUser(username string, pokemon *CaughtPokemon) (*User, error) {
+	pokemonData, err := loadCaught(pokemon, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &User{
+		Username:    username,
+		Pokemons:    []string{pokemonData.Monster.Name},
+		PokemonData: []*PokemonData{pokemonData},
+	}, nil
+}
@@ -0,0 +1,53 @@
+package usermanager
+
+import (
+	"testing"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+func TestWildBattle(t *testing.T) {
+	um := NewUserManagerWithSeed(3)
+	um.KeepHP = true
+	player, err := NewPokemonUser("ash", &CaughtPokemon{Name: "Pikachu", Instance: models.Instance{Level: 50, IVs: perfectIVs, CurrentHP: 40}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wild, err := NewPokemonUser("wild", &CaughtPokemon{Name: "Bulbasaur", Instance: models.Instance{Level: 3, CurrentHP: 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wild.Bot = &Bot{Difficulty: DifficultyRandom}
+	um.JoinUser(player)
+	um.JoinUser(wild)
+
+	// Both sides start with the HP they came in with
+	um.StartBattle()
+	if player.Active[0].CurrentHP != 40 || wild.Active[0].CurrentHP != 5 {
+		t.Fatalf("battle started with %d and %d HP, want 40 and 5", player.Active[0].CurrentHP, wild.Active[0].CurrentHP)
+	}
+	um.playBots()
+	for turns := 0; um.BattleStarted; turns++ {
+		if turns > 100 {
+			t.Fatal("battle never finished")
+		}
+		um.handleCommand(Command{Username: "ash", Name: "move", Arg: "discharge"})
+		um.playBots()
+	}
+	if um.Winner != "ash" {
+		t.Fatalf("winner = %s, want ash", um.Winner)
+	}
+
+	// The HP they end on is kept, and the Pikachu earned experience for the
+	// knockout; the wild Pokemon earned nothing
+	pikachu, bulbasaur := player.PokemonData[0], wild.PokemonData[0]
+	if pikachu.Instance.CurrentHP != pikachu.CurrentHP || bulbasaur.Instance.CurrentHP != 0 {
+		t.Errorf("individuals kept %d and %d HP, want %d and 0", pikachu.Instance.CurrentHP, bulbasaur.Instance.CurrentHP, pikachu.CurrentHP)
+	}
+	if want := models.ExperienceForLevel(50) + models.ExperienceYield(bulbasaur.Monster, 3); pikachu.Instance.Experience != want {
+		t.Errorf("Pikachu's experience = %d, want %d", pikachu.Instance.Experience, want)
+	}
+	if bulbasaur.Instance.Experience != 0 {
+		t.Errorf("the wild Bulbasaur gained %d experience", bulbasaur.Instance.Experience)
+	}
+}
@@ -0,0 +1,200 @@
+// Package growth is how Pokemon grow between battles: gaining experience,
+// levelling up, learning moves and evolving. battleServer and pokeCatserver
+// share it, so a Pokemon grows the same way in both.
+package growth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+const (
+	// movesetSize is the most moves a Pokemon can know.
+	movesetSize = 4
+	// levelUpLearnType marks the moves of a learnset learned by levelling
+	// up, and levelUpEvolution the evolutions that happen at a level.
+	levelUpLearnType = "level up"
+	levelUpEvolution = "level_up"
+)
+
+// Species is what growing Pokemon need to know about a species: its base
+// stats, what it evolves into and the moves it learns by level.
+type Species struct {
+	Monster   *models.Monster
+	Evolution *models.Evolution
+	// Learnset lists how and at which level the species learns its moves,
+	// and Moves holds those moves by id.
+	Learnset []models.LearnableMove
+	Moves    map[int]*models.Move
+}
+
+// LoadSpecies reads a species from the data directory by national id.
+func LoadSpecies(dataPath string, id int) (*Species, error) {
+	data, err := os.ReadFile(fmt.Sprintf("%s/monsters/data/%d.json", dataPath, id))
+	if err != nil {
+		return nil, err
+	}
+	var monster struct {
+		Monster      *models.Monster   `json:"monster"`
+		Evolution    *models.Evolution `json:"evolution"`
+		MonsterMoves []*models.Move    `json:"monster_moves"`
+	}
+	if err := json.Unmarshal(data, &monster); err != nil {
+		return nil, fmt.Errorf("species %d: %v", id, err)
+	}
+	if monster.Monster == nil {
+		return nil, fmt.Errorf("species %d has no monster data", id)
+	}
+
+	data, err = os.ReadFile(fmt.Sprintf("%s/monster_moves/data/%d.json", dataPath, id))
+	if err != nil {
+		return nil, err
+	}
+	var learnset models.MonsterMove
+	if err := json.Unmarshal(data, &learnset); err != nil {
+		return nil, fmt.Errorf("species %d learnset: %v", id, err)
+	}
+
+	species := &Species{Monster: monster.Monster, Evolution: monster.Evolution, Learnset: learnset.Move, Moves: make(map[int]*models.Move)}
+	for _, move := range monster.MonsterMoves {
+		if moveID, err := strconv.Atoi(move.ID); err == nil {
+			species.Moves[moveID] = move
+		}
+	}
+	return species, nil
+}
+
+// learnedAt returns the moves the species learns on reaching a level.
+func (s *Species) learnedAt(level int) []*models.Move {
+	var moves []*models.Move
+	for _, entry := range s.Learnset {
+		if move, ok := s.Moves[entry.Id]; ok && entry.LearnType == levelUpLearnType && entry.Level == level {
+			moves = append(moves, move)
+		}
+	}
+	return moves
+}
+
+// evolutionAt returns what the species evolves into at a level, if it
+// evolves by levelling up and has reached the level it needs.
+func (s *Species) evolutionAt(level int) (models.Up, bool) {
+	if s.Evolution == nil {
+		return models.Up{}, false
+	}
+	for _, up := range s.Evolution.To {
+		if up.Method == levelUpEvolution && up.Level > 0 && level >= up.Level {
+			return up, true
+		}
+	}
+	return models.Up{}, false
+}
+
+// DefaultMoves returns the identifiers of the last moves, up to four, a
+// species has learned by levelling up to a level.
+func DefaultMoves(species *Species, level int) []string {
+	var moves []string
+	for l := 1; l <= level; l++ {
+		for _, move := range species.learnedAt(l) {
+			moves = learn(moves, move.Identifier)
+		}
+	}
+	return moves
+}
+
+// knows reports whether a moveset has a move.
+func knows(moves []string, move string) bool {
+	for _, known := range moves {
+		if strings.EqualFold(known, move) {
+			return true
+		}
+	}
+	return false
+}
+
+// learn adds a move to a moveset, forgetting the oldest move if it is full.
+func learn(moves []string, move string) []string {
+	if knows(moves, move) {
+		return moves
+	}
+	if len(moves) == movesetSize {
+		moves = moves[1:]
+	}
+	return append(append([]string(nil), moves...), move)
+}
+
+// Gain gives a Pokemon experience. For each level it grows it learns the
+// level-up moves of that level, forgetting its oldest move once it knows
+// four, and it evolves when it reaches its evolution level. Gain returns the
+// Pokemon's species afterwards, which differs from before if it evolved,
+// and a message for each thing that happened.
+func Gain(dataPath string, pokemon *models.Instance, experience int) (*Species, []string, error) {
+	species, err := LoadSpecies(dataPath, pokemon.SpeciesID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(pokemon.Moves) == 0 {
+		pokemon.Moves = DefaultMoves(species, pokemon.Level)
+	}
+	pokemon.Experience = max(pokemon.Experience, models.ExperienceForLevel(pokemon.Level)) + experience
+
+	name := species.Monster.Name
+	messages := []string{fmt.Sprintf("%s gained %d experience.", name, experience)}
+	for pokemon.Level < models.MaxLevel && pokemon.Experience >= models.ExperienceForLevel(pokemon.Level+1) {
+		maxHP := pokemon.Stats(species.Monster).HP
+		pokemon.Level++
+		messages = append(messages, fmt.Sprintf("%s grew to level %d!", name, pokemon.Level))
+		messages = append(messages, learnMoves(pokemon, species)...)
+
+		if up, ok := species.evolutionAt(pokemon.Level); ok {
+			evolved, err := LoadSpecies(dataPath, up.NationalId)
+			if err != nil {
+				return species, messages, err
+			}
+			messages = append(messages, fmt.Sprintf("%s evolved into %s!", name, evolved.Monster.Name))
+			species, name = evolved, evolved.Monster.Name
+			pokemon.SpeciesID = up.NationalId
+			messages = append(messages, learnMoves(pokemon, species)...)
+		}
+
+		// The HP a level or an evolution adds is added to the current HP. A
+		// Pokemon at 0 has fainted, or has never had its HP set, and stays
+		// at 0
+		if pokemon.CurrentHP > 0 {
+			pokemon.CurrentHP = max(1, pokemon.CurrentHP+pokemon.Stats(species.Monster).HP-maxHP)
+		}
+	}
+	return species, messages, nil
+}
+
+// learnMoves teaches a Pokemon the moves its species learns at its level.
+func learnMoves(pokemon *models.Instance, species *Species) []string {
+	var messages []string
+	for _, move := range species.learnedAt(pokemon.Level) {
+		switch {
+		case knows(pokemon.Moves, move.Identifier):
+			continue
+		case len(pokemon.Moves) == movesetSize:
+			messages = append(messages, fmt.Sprintf("%s forgot %s and learned %s!", species.Monster.Name, species.moveName(pokemon.Moves[0]), move.Name))
+		default:
+			messages = append(messages, fmt.Sprintf("%s learned %s!", species.Monster.Name, move.Name))
+		}
+		pokemon.Moves = learn(pokemon.Moves, move.Identifier)
+	}
+	return messages
+}
+
+// moveName returns the name of a move the species can learn, given its
+// identifier.
+func (s *Species) moveName(identifier string) string {
+	for _, move := range s.Moves {
+		if strings.EqualFold(move.Identifier, identifier) {
+			return move.Name
+		}
+	}
+	return identifier
+}
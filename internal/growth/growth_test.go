@@ -0,0 +1,113 @@
+package growth
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+const dataPath = "../models"
+
+func TestGain(t *testing.T) {
+	tests := []struct {
+		name        string
+		level       int
+		experience  int
+		wantSpecies string
+		wantLevel   int
+		wantMoves   []string
+		wantMessage string
+	}{
+		{
+			name:        "not enough for a level",
+			level:       12,
+			experience:  100,
+			wantSpecies: "Bulbasaur",
+			wantLevel:   12,
+			wantMoves:   []string{"growl", "tackle", "leech-seed"},
+			wantMessage: "Bulbasaur gained 100 experience.",
+		},
+		{
+			name:        "learns a move",
+			level:       12,
+			experience:  models.ExperienceForLevel(13) - models.ExperienceForLevel(12),
+			wantSpecies: "Bulbasaur",
+			wantLevel:   13,
+			wantMoves:   []string{"growl", "tackle", "leech-seed", "vine-whip"},
+			wantMessage: "Bulbasaur learned Vine-whip!",
+		},
+		{
+			name:        "evolves",
+			level:       12,
+			experience:  models.ExperienceForLevel(16) - models.ExperienceForLevel(12),
+			wantSpecies: "Ivysaur",
+			wantLevel:   16,
+			wantMoves:   []string{"growl", "tackle", "leech-seed", "vine-whip"},
+			wantMessage: "Bulbasaur evolved into Ivysaur!",
+		},
+		{
+			name:        "forgets a move after evolving",
+			level:       12,
+			experience:  models.ExperienceForLevel(22) - models.ExperienceForLevel(12),
+			wantSpecies: "Ivysaur",
+			wantLevel:   22,
+			wantMoves:   []string{"tackle", "leech-seed", "vine-whip", "poison-powder"},
+			wantMessage: "Ivysaur forgot Growl and learned Poisonpowder!",
+		},
+		{
+			name:        "max level",
+			level:       models.MaxLevel,
+			experience:  1000000,
+			wantSpecies: "Bulbasaur",
+			wantLevel:   models.MaxLevel,
+			wantMoves:   []string{"seed-bomb", "synthesis", "sleep-powder", "solar-beam"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pokemon := &models.Instance{SpeciesID: 1, Level: tt.level}
+			species, messages, err := Gain(dataPath, pokemon, tt.experience)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if species.Monster.Name != tt.wantSpecies || pokemon.SpeciesID != species.Monster.NationalID || pokemon.Level != tt.wantLevel {
+				t.Errorf("Gain() left a level %d %s (species %d), want a level %d %s", pokemon.Level, species.Monster.Name, pokemon.SpeciesID, tt.wantLevel, tt.wantSpecies)
+			}
+			if !reflect.DeepEqual(pokemon.Moves, tt.wantMoves) {
+				t.Errorf("moves = %v, want %v", pokemon.Moves, tt.wantMoves)
+			}
+			if tt.wantMessage != "" && !strings.Contains(strings.Join(messages, "\n"), tt.wantMessage) {
+				t.Errorf("messages = %q, want %q among them", messages, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestGain_currentHP(t *testing.T) {
+	// Bulbasaur at level 15 has 45 base HP, and grows to a level 16 Ivysaur
+	before := (&models.Instance{Level: 15}).Stats(&models.Monster{HP: 45}).HP
+	after := (&models.Instance{Level: 16}).Stats(&models.Monster{HP: 60}).HP
+	tests := []struct {
+		name      string
+		currentHP int
+		want      int
+	}{
+		// A hurt Pokemon keeps its damage when it levels up
+		{name: "hurt", currentHP: 10, want: 10 + after - before},
+		// 0 is a fainted Pokemon, or one whose HP was never set
+		{name: "no HP", currentHP: 0, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pokemon := &models.Instance{SpeciesID: 1, Level: 15, CurrentHP: tt.currentHP}
+			if _, _, err := Gain(dataPath, pokemon, models.ExperienceForLevel(16)-models.ExperienceForLevel(15)); err != nil {
+				t.Fatal(err)
+			}
+			if pokemon.CurrentHP != tt.want {
+				t.Errorf("CurrentHP = %d, want %d", pokemon.CurrentHP, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// Ball is a kind of ball thrown to catch wild Pokemon.
+type Ball struct {
+	Name string
+	// Bonus multiplies the chance of a catch. A Master Ball never fails.
+	Bonus  float64
+	Master bool
+}
+
+// Balls holds the balls a trainer can throw, by the name used to pick them.
+var Balls = map[string]Ball{
+	"poke":   {Name: "Poke Ball", Bonus: 1},
+	"great":  {Name: "Great Ball", Bonus: 1.5},
+	"ultra":  {Name: "Ultra Ball", Bonus: 2},
+	"master": {Name: "Master Ball", Master: true},
+}
+
+// BallNames lists the keys of Balls from worst to best.
+var BallNames = []string{"poke", "great", "ultra", "master"}
+
+// FindBall looks up a ball by name, accepting "great", "great ball" and
+// "great-ball" alike.
+func FindBall(name string) (Ball, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(name, "ball"), "-"))
+	ball, ok := Balls[name]
+	return ball, ok
+}
+
+// CatchRate returns how easily a species is caught, from 3 for the hardest
+// to 255 for the easiest. Every catch_rate in the data is 0, so the rate is
+// always estimated from the species' base stat total: the stronger the
+// species, the harder it is to catch. For common species the estimate is
+// within 70 of the rate in the games, but starters, legendaries and others
+// the games keep rare come out too easy, e.g. 181 for Bulbasaur against 45.
+// A rate set on the species is used as it is.
+func CatchRate(species *Monster) int {
+	if species.CatchRate > 0 {
+		return species.CatchRate
+	}
+	return max(3, min(255, (680-BaseStats(species).Total())/2))
+}
+
+// catchValue is the modified catch rate of the main series games: it rises
+// as the wild Pokemon loses HP and with better balls. 255 or more is a
+// certain catch.
+func catchValue(catchRate, currentHP, maxHP int, ball Ball) float64 {
+	if ball.Master {
+		return 255
+	}
+	maxHP = max(1, maxHP)
+	currentHP = max(1, min(currentHP, maxHP))
+	return float64(3*maxHP-2*currentHP) * float64(catchRate) * ball.Bonus / float64(3*maxHP)
+}
+
+// CatchChance is the probability, from 0 to 1, that a ball catches a wild
+// Pokemon.
+func CatchChance(catchRate, currentHP, maxHP int, ball Ball) float64 {
+	a := catchValue(catchRate, currentHP, maxHP, ball)
+	if a >= 255 {
+		return 1
+	}
+	return math.Pow(shakeThreshold(a)/65536, 4)
+}
+
+// shakeThreshold is the value each of the ball's four shake checks must roll
+// under for the Pokemon to stay in.
+func shakeThreshold(a float64) float64 {
+	if a <= 0 {
+		return 0
+	}
+	return 1048560 / math.Sqrt(math.Sqrt(16711680/a))
+}
+
+// ThrowBall throws a ball at a wild Pokemon. The ball shakes once for each
+// check the Pokemon fails to break free at, and catches it if it passes all
+// four; it returns the number of shakes, up to three, and whether the
+// Pokemon was caught.
+func ThrowBall(catchRate, currentHP, maxHP int, ball Ball, rng *rand.Rand) (int, bool) {
+	a := catchValue(catchRate, currentHP, maxHP, ball)
+	if a >= 255 {
+		return 3, true
+	}
+	threshold := shakeThreshold(a)
+	for shakes := 0; shakes < 4; shakes++ {
+		if float64(rng.Intn(65536)) >= threshold {
+			return min(shakes, 3), false
+		}
+	}
+	return 3, true
+}
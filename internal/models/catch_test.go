@@ -0,0 +1,121 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestCatchChance(t *testing.T) {
+	tests := []struct {
+		name      string
+		catchRate int
+		currentHP int
+		maxHP     int
+		ball      string
+		want      float64
+	}{
+		{name: "easy catch at full HP", catchRate: 255, currentHP: 100, maxHP: 100, ball: "poke", want: 0.333},
+		{name: "easy catch at 1 HP", catchRate: 255, currentHP: 1, maxHP: 100, ball: "poke", want: 0.993},
+		{name: "starter at full HP", catchRate: 45, currentHP: 100, maxHP: 100, ball: "poke", want: 0.059},
+		{name: "starter at full HP in an ultra ball", catchRate: 45, currentHP: 100, maxHP: 100, ball: "ultra", want: 0.118},
+		{name: "legendary at 1 HP", catchRate: 3, currentHP: 1, maxHP: 100, ball: "ultra", want: 0.023},
+		{name: "master ball", catchRate: 3, currentHP: 100, maxHP: 100, ball: "master", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ball, ok := FindBall(tt.ball)
+			if !ok {
+				t.Fatalf("FindBall(%q) found nothing", tt.ball)
+			}
+			if got := CatchChance(tt.catchRate, tt.currentHP, tt.maxHP, ball); math.Abs(got-tt.want) > 0.001 {
+				t.Errorf("CatchChance() = %.3f, want %.3f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatchRate(t *testing.T) {
+	tests := []struct {
+		id int
+		// games is the species' catch rate in the games, which the
+		// estimate is checked against.
+		games int
+		want  int
+	}{
+		{id: 10, games: 255, want: 242}, // Caterpie
+		{id: 16, games: 255, want: 214}, // Pidgey
+		{id: 25, games: 190, want: 180}, // Pikachu
+		{id: 74, games: 255, want: 190}, // Geodude
+		{id: 75, games: 120, want: 145}, // Graveler
+		{id: 76, games: 45, want: 92},   // Golem
+		{id: 143, games: 25, want: 70},  // Snorlax
+		{id: 149, games: 45, want: 40},  // Dragonite
+		{id: 144, games: 3, want: 50},   // Articuno
+		{id: 150, games: 3, want: 3},    // Mewtwo
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(fmt.Sprintf("monsters/data/%d.json", tt.id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var monster struct {
+			Monster *Monster `json:"monster"`
+		}
+		if err := json.Unmarshal(data, &monster); err != nil {
+			t.Fatal(err)
+		}
+		t.Run(monster.Monster.Name, func(t *testing.T) {
+			if monster.Monster.CatchRate != 0 {
+				t.Errorf("data has a catch rate of %d, the estimate is no longer needed", monster.Monster.CatchRate)
+			}
+			got := CatchRate(monster.Monster)
+			if got != tt.want {
+				t.Errorf("CatchRate() = %d, want %d", got, tt.want)
+			}
+			if diff := got - tt.games; diff < -70 || diff > 70 {
+				t.Errorf("CatchRate() = %d, more than 70 from %d in the games", got, tt.games)
+			}
+		})
+	}
+
+	// A rate on the species is used as it is
+	if got := CatchRate(&Monster{CatchRate: 45, HP: 45}); got != 45 {
+		t.Errorf("CatchRate() with a catch rate of 45 = %d", got)
+	}
+}
+
+func TestThrowBall(t *testing.T) {
+	// Over many throws, the share of catches matches the chance
+	rng := rand.New(rand.NewSource(1))
+	ball := Balls["great"]
+	const throws = 20000
+	caught := 0
+	for i := 0; i < throws; i++ {
+		shakes, ok := ThrowBall(45, 30, 100, ball, rng)
+		if shakes < 0 || shakes > 3 {
+			t.Fatalf("ThrowBall() shook %d times", shakes)
+		}
+		if ok {
+			caught++
+		}
+	}
+	want := CatchChance(45, 30, 100, ball)
+	if got := float64(caught) / throws; math.Abs(got-want) > 0.02 {
+		t.Errorf("caught %.3f of throws, want about %.3f", got, want)
+	}
+}
+
+func TestFindBall(t *testing.T) {
+	for _, name := range []string{"great", "Great Ball", "great-ball", "greatball"} {
+		if ball, ok := FindBall(name); !ok || ball.Name != "Great Ball" {
+			t.Errorf("FindBall(%q) = %v, %v, want the Great Ball", name, ball, ok)
+		}
+	}
+	if _, ok := FindBall("dive"); ok {
+		t.Error("FindBall() found a ball that doesn't exist")
+	}
+}
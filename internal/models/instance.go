@@ -0,0 +1,215 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	mathrand "math/rand"
+)
+
+const (
+	// MaxIV is the highest individual value a stat can have.
+	MaxIV = 31
+	// MaxStatEV is the most effort values one stat can have, and MaxTotalEV
+	// the most across all six.
+	MaxStatEV  = 252
+	MaxTotalEV = 510
+	// MaxLevel is the highest level a Pokemon can reach.
+	MaxLevel = 100
+)
+
+// Stats holds a number for each of a Pokemon's six stats. It is used for
+// computed stats as well as IVs, EVs and EV yields.
+type Stats struct {
+	HP      int `json:"hp"`
+	Attack  int `json:"attack"`
+	Defense int `json:"defense"`
+	SpAtk   int `json:"sp_atk"`
+	SpDef   int `json:"sp_def"`
+	Speed   int `json:"speed"`
+}
+
+// statNames names the stats in the order fields returns them.
+var statNames = []string{"hp", "attack", "defense", "sp_atk", "sp_def", "speed"}
+
+func (s *Stats) fields() []*int {
+	return []*int{&s.HP, &s.Attack, &s.Defense, &s.SpAtk, &s.SpDef, &s.Speed}
+}
+
+// Total adds up all six stats.
+func (s Stats) Total() int {
+	return s.HP + s.Attack + s.Defense + s.SpAtk + s.SpDef + s.Speed
+}
+
+// Add adds two sets of stats together.
+func (s Stats) Add(other Stats) Stats {
+	values, others := s.fields(), other.fields()
+	for n := range values {
+		*values[n] += *others[n]
+	}
+	return s
+}
+
+// BaseStats returns a species' base stats.
+func BaseStats(species *Monster) Stats {
+	return Stats{
+		HP:      species.HP,
+		Attack:  species.Attack,
+		Defense: species.Defense,
+		SpAtk:   species.SpAtk,
+		SpDef:   species.SpDef,
+		Speed:   species.Speed,
+	}
+}
+
+// EVYield is the EVs a species gives the Pokemon that defeats it.
+func EVYield(supplemental *MonsterSupplemental) Stats {
+	if supplemental == nil {
+		return Stats{}
+	}
+	return Stats{
+		HP:      supplemental.HpEV,
+		Attack:  supplemental.AttackEV,
+		Defense: supplemental.DefenseEV,
+		SpAtk:   supplemental.SpecialAttackEV,
+		SpDef:   supplemental.SpecialDefenseEV,
+		Speed:   supplemental.SpeedEV,
+	}
+}
+
+// Nature raises one stat by 10% and lowers another by 10%. Neutral natures
+// raise and lower the same stat, which changes nothing.
+type Nature struct {
+	Raised  string
+	Lowered string
+}
+
+// natureStats are the stats a nature can change.
+var natureStats = []string{"attack", "defense", "speed", "sp_atk", "sp_def"}
+
+// natureTable lays the natures out by the stat they raise (row) and the one
+// they lower (column), both in the order of natureStats.
+var natureTable = [][]string{
+	{"hardy", "lonely", "brave", "adamant", "naughty"},
+	{"bold", "docile", "relaxed", "impish", "lax"},
+	{"timid", "hasty", "serious", "jolly", "naive"},
+	{"modest", "mild", "quiet", "bashful", "rash"},
+	{"calm", "gentle", "sassy", "careful", "quirky"},
+}
+
+// Natures holds all 25 natures by name.
+var Natures = func() map[string]Nature {
+	natures := make(map[string]Nature)
+	for raised, row := range natureTable {
+		for lowered, name := range row {
+			natures[name] = Nature{Raised: natureStats[raised], Lowered: natureStats[lowered]}
+		}
+	}
+	return natures
+}()
+
+func (n Nature) multiplier(stat string) float64 {
+	switch {
+	case n.Raised == n.Lowered:
+		return 1
+	case stat == n.Raised:
+		return 1.1
+	case stat == n.Lowered:
+		return 0.9
+	}
+	return 1
+}
+
+// Instance is one individual Pokemon rather than its species. Two Pokemon of
+// the same species differ in their level, IVs, EVs and nature, and so in
+// their stats.
+type Instance struct {
+	// ID tells individuals apart, so one that changed in a battle can be
+	// saved back over itself.
+	ID        string `json:"id,omitempty"`
+	SpeciesID int    `json:"species_id,omitempty"`
+	Level     int    `json:"level"`
+	IVs       Stats  `json:"ivs"`
+	EVs       Stats  `json:"evs"`
+	// Nature is a key of Natures. Empty is neutral.
+	Nature     string   `json:"nature,omitempty"`
+	CurrentHP  int      `json:"current_hp"`
+	Moves      []string `json:"moves,omitempty"`
+	Experience int      `json:"experience"`
+}
+
+// NewInstance creates a wild Pokemon of a species: random IVs and nature, no
+// EVs and full HP.
+func NewInstance(species *Monster, level int, rng *mathrand.Rand) *Instance {
+	instance := &Instance{ID: NewInstanceID(), SpeciesID: species.NationalID, Level: level}
+	for _, iv := range instance.IVs.fields() {
+		*iv = rng.Intn(MaxIV + 1)
+	}
+	row := natureTable[rng.Intn(len(natureTable))]
+	instance.Nature = row[rng.Intn(len(row))]
+	instance.CurrentHP = instance.Stats(species).HP
+	return instance
+}
+
+// NewInstanceID returns a random ID for a new individual.
+func NewInstanceID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Stats computes the individual's stats from its species' base stats with
+// the formulas of the main series games.
+func (i *Instance) Stats(species *Monster) Stats {
+	level := i.Level
+	if level < 1 {
+		level = 1
+	}
+	nature := Natures[i.Nature]
+
+	base := BaseStats(species)
+	var stats Stats
+	out, ivs, evs := stats.fields(), i.IVs.fields(), i.EVs.fields()
+	for n, b := range base.fields() {
+		value := (2**b + *ivs[n] + *evs[n]/4) * level / 100
+		if statNames[n] == "hp" {
+			*out[n] = value + level + 10
+		} else {
+			*out[n] = int(float64(value+5) * nature.multiplier(statNames[n]))
+		}
+	}
+	return stats
+}
+
+// AddEVs gives the individual EVs, such as the yield of a Pokemon it
+// defeated, without going over either cap. It returns the EVs actually
+// gained.
+func (i *Instance) AddEVs(yield Stats) Stats {
+	var gained Stats
+	evs, gains := i.EVs.fields(), gained.fields()
+	for n, y := range yield.fields() {
+		add := min(*y, MaxStatEV-*evs[n], MaxTotalEV-i.EVs.Total())
+		if add > 0 {
+			*evs[n] += add
+			*gains[n] = add
+		}
+	}
+	return gained
+}
+
+// ExperienceForLevel is the total experience a Pokemon needs to reach a
+// level. Every species grows at the main series' medium fast rate, the level
+// cubed.
+func ExperienceForLevel(level int) int {
+	if level <= 1 {
+		return 0
+	}
+	return level * level * level
+}
+
+// ExperienceYield is the experience a Pokemon earns for defeating another of
+// a species and level. The data has no base experience, so it is estimated
+// from the species' base stat total.
+func ExperienceYield(species *Monster, level int) int {
+	baseExperience := BaseStats(species).Total() / 5
+	return max(1, baseExperience*level/7)
+}
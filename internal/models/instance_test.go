@@ -0,0 +1,79 @@
+package models
+
+import (
+	"math/rand"
+	"testing"
+)
+
+var pikachu = &Monster{Name: "Pikachu", NationalID: 25, HP: 35, Attack: 55, Defense: 40, SpAtk: 50, SpDef: 50, Speed: 90}
+
+func TestInstance_Stats(t *testing.T) {
+	perfect := Stats{HP: MaxIV, Attack: MaxIV, Defense: MaxIV, SpAtk: MaxIV, SpDef: MaxIV, Speed: MaxIV}
+	tests := []struct {
+		name     string
+		instance Instance
+		want     Stats
+	}{
+		{
+			name:     "level 50, perfect IVs, neutral",
+			instance: Instance{Level: 50, IVs: perfect},
+			want:     Stats{HP: 110, Attack: 75, Defense: 60, SpAtk: 70, SpDef: 70, Speed: 110},
+		},
+		{
+			name:     "level 100, maxed speed, timid",
+			instance: Instance{Level: 100, IVs: perfect, EVs: Stats{HP: 4, SpAtk: 252, Speed: 252}, Nature: "timid"},
+			want:     Stats{HP: 212, Attack: 131, Defense: 116, SpAtk: 199, SpDef: 136, Speed: 306},
+		},
+		{
+			name:     "level 5, no IVs, neutral nature by name",
+			instance: Instance{Level: 5, Nature: "hardy"},
+			want:     Stats{HP: 18, Attack: 10, Defense: 9, SpAtk: 10, SpDef: 10, Speed: 14},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.instance.Stats(pikachu); got != tt.want {
+				t.Errorf("Stats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstance_AddEVs(t *testing.T) {
+	tests := []struct {
+		name       string
+		evs        Stats
+		yield      Stats
+		wantGained Stats
+		wantEVs    Stats
+	}{
+		{name: "no EVs yet", yield: Stats{Speed: 2}, wantGained: Stats{Speed: 2}, wantEVs: Stats{Speed: 2}},
+		{name: "stat cap", evs: Stats{Speed: 251}, yield: Stats{Speed: 2, HP: 1}, wantGained: Stats{Speed: 1, HP: 1}, wantEVs: Stats{Speed: 252, HP: 1}},
+		{name: "total cap", evs: Stats{Attack: 252, Speed: 252, HP: 5}, yield: Stats{Defense: 3}, wantGained: Stats{Defense: 1}, wantEVs: Stats{Attack: 252, Speed: 252, HP: 5, Defense: 1}},
+		{name: "full", evs: Stats{Attack: 252, Speed: 252, HP: 6}, yield: Stats{SpAtk: 3}, wantEVs: Stats{Attack: 252, Speed: 252, HP: 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := &Instance{EVs: tt.evs}
+			if got := instance.AddEVs(tt.yield); got != tt.wantGained {
+				t.Errorf("AddEVs() = %+v, want %+v", got, tt.wantGained)
+			}
+			if instance.EVs != tt.wantEVs {
+				t.Errorf("EVs = %+v, want %+v", instance.EVs, tt.wantEVs)
+			}
+		})
+	}
+}
+
+func TestNewInstance(t *testing.T) {
+	instance := NewInstance(pikachu, 12, rand.New(rand.NewSource(1)))
+	if instance.SpeciesID != 25 || instance.Level != 12 || instance.ID == "" {
+		t.Errorf("NewInstance() = %+v, want a level 12 Pikachu with an ID", instance)
+	}
+	if _, ok := Natures[instance.Nature]; !ok {
+		t.Errorf("nature %q isn't one of Natures", instance.Nature)
+	}
+	if instance.EVs.Total() != 0 || instance.CurrentHP != instance.Stats(pikachu).HP {
+		t.Errorf("NewInstance() = %+v, want no EVs and full HP", instance)
+	}
+}
@@ -0,0 +1,21 @@
+package models
+
+// MoveEffect is what a move does in battle besides its damage. Effects live in
+// move_effects/data/move_effects.json keyed by the move's identifier.
+type MoveEffect struct {
+	// Status is the condition the move may inflict on its target.
+	Status string `json:"status,omitempty"`
+	// StatChanges are the stat stages the move raises or lowers.
+	StatChanges []StatChange `json:"stat_changes,omitempty"`
+	// Chance is the percent chance of the effect happening. A missing chance
+	// means the effect always happens.
+	Chance int `json:"chance,omitempty"`
+}
+
+// StatChange raises or lowers one stat of the target, or of the user when Self
+// is set, by a number of stages.
+type StatChange struct {
+	Stat   string `json:"stat"`
+	Stages int    `json:"stages"`
+	Self   bool   `json:"self,omitempty"`
+}
@@ -0,0 +1,93 @@
+// Package playerfile guards pokeCatserver's players.json, which both
+// pokeCatserver and battleServer rewrite: pokeCat when a player catches or
+// fights a Pokemon, battleServer when caught Pokemon grow in its battles.
+// Every change goes through Update, which reads, changes and replaces the
+// file while holding a lock file, so neither server writes over what the
+// other has just saved.
+package playerfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// lockTimeout is how long Update waits for the other server to finish.
+	lockTimeout = 10 * time.Second
+	// staleLock is how old a lock file has to be before it is taken to be
+	// left behind by a server that died holding it. Updates take
+	// milliseconds.
+	staleLock = 30 * time.Second
+	// lockRetry is how often a held lock is tried again.
+	lockRetry = 10 * time.Millisecond
+)
+
+// ErrLocked is returned when the lock isn't released in time.
+var ErrLocked = errors.New("locked by another update")
+
+// Update passes the contents of the file at path to change and replaces the
+// file with what it returns, holding the file's lock throughout. A missing
+// file is passed as nil. When change fails the file is left as it was.
+func Update(path string, change func(data []byte) ([]byte, error)) error {
+	unlock, err := lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if data, err = change(data); err != nil {
+		return err
+	}
+	return writeAtomic(path, data)
+}
+
+// lock takes the lock on path by creating path+".lock", waiting while
+// another update holds it. The returned func releases it.
+func lock(path string) (func(), error) {
+	name := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(name) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(name)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s: %w", path, ErrLocked)
+		}
+		time.Sleep(lockRetry)
+	}
+}
+
+// writeAtomic replaces a file in one step, so that it is never read half
+// written.
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
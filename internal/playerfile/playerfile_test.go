@@ -0,0 +1,80 @@
+package playerfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "players.json")
+
+	// A missing file is passed as nil
+	err := Update(path, func(data []byte) ([]byte, error) {
+		if data != nil {
+			t.Errorf("missing file was passed as %q", data)
+		}
+		return []byte("0"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent updates each see the one before, so none is lost
+	const updates = 20
+	var wg sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := Update(path, func(data []byte) ([]byte, error) {
+				n, err := strconv.Atoi(string(data))
+				return []byte(strconv.Itoa(n + 1)), err
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if data, _ := os.ReadFile(path); string(data) != strconv.Itoa(updates) {
+		t.Errorf("file = %q after %d updates, want %d", data, updates, updates)
+	}
+
+	// A failed change leaves the file alone and releases the lock
+	failed := errors.New("failed")
+	err = Update(path, func([]byte) ([]byte, error) {
+		return []byte("lost"), failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("Update() = %v, want %v", err, failed)
+	}
+	if data, _ := os.ReadFile(path); string(data) != strconv.Itoa(updates) {
+		t.Errorf("file = %q after a failed update, want it unchanged", data)
+	}
+	if _, err := os.Stat(path + ".lock"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("lock file was left behind: %v", err)
+	}
+}
+
+func TestUpdate_staleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "players.json")
+	if err := os.WriteFile(path+".lock", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * staleLock)
+	if err := os.Chtimes(path+".lock", old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Update(path, func([]byte) ([]byte, error) {
+		return []byte("[]"), nil
+	})
+	if err != nil {
+		t.Errorf("Update() with a stale lock = %v, want it broken", err)
+	}
+}
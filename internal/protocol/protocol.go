@@ -0,0 +1,172 @@
+// Package protocol defines the messages exchanged between battleServer and
+// battleClient. Every message is a single JSON object on its own line.
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Version is the protocol version spoken by this build. Messages with any
+// other version are rejected.
+const Version = 1
+
+// maxMessageSize bounds a single line so a misbehaving peer can't exhaust
+// memory.
+const maxMessageSize = 64 * 1024
+
+// Message types.
+const (
+	// TypeLogin is sent by the client with its Username, and its Password
+	// when the server keeps accounts.
+	TypeLogin = "login"
+	// TypeRegister is sent by the client with a Username and Password to
+	// create an account and log in to it.
+	TypeRegister = "register"
+	// TypeTeam is sent by the client with its Team.
+	TypeTeam = "team"
+	// TypeAction is sent by the client with a lobby or battle Command and
+	// its Arg, e.g. "challenge" and a username, or "move" and a move.
+	TypeAction = "action"
+	// TypeState is sent by the server with a human readable Text update.
+	TypeState = "state"
+	// TypeResult is sent by the server when a battle ends, with the Winner.
+	TypeResult = "result"
+	// TypeError is sent by the server when a message is rejected.
+	TypeError = "error"
+)
+
+// Error codes sent in TypeError messages.
+const (
+	ErrCodeMalformed          = "malformed"
+	ErrCodeUnsupportedVersion = "unsupported_version"
+	ErrCodeUnknownType        = "unknown_type"
+	ErrCodeInvalid            = "invalid"
+	// ErrCodeIllegalTeam rejects a team that breaks the battle format's
+	// rules.
+	ErrCodeIllegalTeam = "illegal_team"
+	// ErrCodeAuth rejects a login with the wrong username or password.
+	ErrCodeAuth = "auth_failed"
+)
+
+// Message is a single protocol message. Which fields are set depends on Type.
+type Message struct {
+	Version  int          `json:"version"`
+	Type     string       `json:"type"`
+	Username string       `json:"username,omitempty"`
+	Password string       `json:"password,omitempty"`
+	Team     []TeamMember `json:"team,omitempty"`
+	Command  string       `json:"command,omitempty"`
+	Arg      string       `json:"arg,omitempty"`
+	Text     string       `json:"text,omitempty"`
+	Winner   string       `json:"winner,omitempty"`
+	Code     string       `json:"code,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// TeamMember is a Pokemon in a submitted team with the moves it should know.
+// With no moves the server picks them.
+type TeamMember struct {
+	Name  string   `json:"name"`
+	Moves []string `json:"moves,omitempty"`
+	// Level is the Pokemon's level. The server picks one when it is zero.
+	Level int `json:"level,omitempty"`
+	// Caught picks a Pokemon the trainer caught in pokeCat, by its 1-based
+	// number in their collection, instead of by Name. It battles at the
+	// level it was caught at.
+	Caught int `json:"caught,omitempty"`
+}
+
+// MalformedError is returned by Reader.Read for a line that isn't a valid
+// message. The connection can keep being read after it.
+type MalformedError struct {
+	Code string
+	Err  error
+}
+
+func (e *MalformedError) Error() string {
+	return fmt.Sprintf("%s message: %v", e.Code, e.Err)
+}
+
+// ErrorMessage builds the reply for a rejected message.
+func ErrorMessage(code string, err error) Message {
+	return Message{Type: TypeError, Code: code, Error: err.Error()}
+}
+
+// StateMessage builds a text update for the client.
+func StateMessage(text string) Message {
+	return Message{Type: TypeState, Text: text}
+}
+
+// Reader reads newline-delimited messages.
+type Reader struct {
+	scanner *bufio.Scanner
+}
+
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), maxMessageSize)
+	return &Reader{scanner: scanner}
+}
+
+// Read returns the next message. Malformed lines, unknown versions and types
+// are reported as a *MalformedError; any other error means the stream is done.
+func (r *Reader) Read() (*Message, error) {
+	for r.scanner.Scan() {
+		line := r.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg Message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return nil, &MalformedError{Code: ErrCodeMalformed, Err: err}
+		}
+		if msg.Version != Version {
+			return nil, &MalformedError{Code: ErrCodeUnsupportedVersion, Err: fmt.Errorf("version %d is not supported, expected %d", msg.Version, Version)}
+		}
+		if !knownType(msg.Type) {
+			return nil, &MalformedError{Code: ErrCodeUnknownType, Err: fmt.Errorf("unknown message type %q", msg.Type)}
+		}
+		return &msg, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Write sends a message as a single line, stamping it with the protocol
+// version. Each message is written with one call so concurrent writers don't
+// interleave.
+func Write(w io.Writer, msg Message) error {
+	msg.Version = Version
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	// Encode terminates the message with the newline
+	if err := encoder.Encode(msg); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// IsMalformed reports whether err is a recoverable *MalformedError.
+func IsMalformed(err error) (*MalformedError, bool) {
+	var malformed *MalformedError
+	ok := errors.As(err, &malformed)
+	return malformed, ok
+}
+
+func knownType(t string) bool {
+	switch t {
+	case TypeLogin, TypeRegister, TypeTeam, TypeAction, TypeState, TypeResult, TypeError:
+		return true
+	}
+	return false
+}
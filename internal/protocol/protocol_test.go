@@ -0,0 +1,69 @@
+package protocol
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantType string
+		wantCode string
+	}{
+		{name: "login", input: `{"version":1,"type":"login","username":"ash ketchum"}`, wantType: TypeLogin},
+		{name: "register", input: `{"version":1,"type":"register","username":"ash","password":"pikachu"}`, wantType: TypeRegister},
+		{name: "skips blank lines", input: "\n\n" + `{"version":1,"type":"action","command":"move","arg":"1"}`, wantType: TypeAction},
+		{name: "not json", input: "ash 1 Pikachu", wantCode: ErrCodeMalformed},
+		{name: "old version", input: `{"version":0,"type":"login"}`, wantCode: ErrCodeUnsupportedVersion},
+		{name: "unknown type", input: `{"version":1,"type":"dance"}`, wantCode: ErrCodeUnknownType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := NewReader(strings.NewReader(tt.input + "\n")).Read()
+			if tt.wantCode != "" {
+				malformed, ok := IsMalformed(err)
+				if !ok || malformed.Code != tt.wantCode {
+					t.Fatalf("Read() error = %v, want code %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if msg.Type != tt.wantType {
+				t.Errorf("Read() type = %s, want %s", msg.Type, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	var buf bytes.Buffer
+	team := Message{Type: TypeTeam, Team: []TeamMember{{Name: "Charizard", Moves: []string{"flamethrower", "slash"}}, {Name: "Blastoise"}}}
+	if err := Write(&buf, team); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(&buf, StateMessage("two\nlines")); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewReader(&buf)
+	got, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != Version || len(got.Team) != 2 || got.Team[0].Moves[1] != "slash" {
+		t.Errorf("Read() = %+v, want the team back", got)
+	}
+	got, err = reader.Read()
+	if err != nil || got.Text != "two\nlines" {
+		t.Errorf("Read() = %+v, %v, want the state text back", got, err)
+	}
+	if _, err := reader.Read(); err != io.EOF {
+		t.Errorf("Read() error = %v, want io.EOF", err)
+	}
+}
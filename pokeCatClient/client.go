@@ -51,14 +51,21 @@ func getUsernameAndPasswordFromInput() (string, string) {
 func readAndSendMoves(conn net.Conn, username, password string) {
     reader := bufio.NewReader(os.Stdin)
     for {
-        fmt.Print("Enter move direction (up, down, left, right): ")
+        fmt.Print("Enter move direction (up, down, left, right), 'throw <ball>', 'fight' or 'run', or a battle move: ")
         text, _ := reader.ReadString('\n')
-        if strings.TrimSpace(text) == "exit" {
+        text = strings.TrimSpace(text)
+        if text == "exit" {
             break
         }
 
-        // Append the username, password, and move direction to the input text
-        text = fmt.Sprintf("%s %s move %s", username, password, strings.TrimSpace(text))
+        // Append the username and password to the input text. Throwing a
+        // ball, fighting and running are actions of their own; anything
+        // else is a move direction, or a move in battle
+        if text == "run" || text == "fight" || strings.HasPrefix(text, "throw") {
+            text = fmt.Sprintf("%s %s %s", username, password, text)
+        } else {
+            text = fmt.Sprintf("%s %s move %s", username, password, text)
+        }
 
         // Send the message to the server
         _, err := conn.Write([]byte(text + "\n"))
@@ -70,17 +77,50 @@ func readAndSendMoves(conn net.Conn, username, password string) {
 }
 
 func readResponsesFromServer(conn net.Conn) {
-    buf := make([]byte, 1024)
+    reader := bufio.NewReader(conn)
     for {
-        n, err := conn.Read(buf)
+        line, err := reader.ReadString('\n')
         if err != nil {
             fmt.Println("Error reading from connection:", err)
             return
         }
-        response := strings.TrimSpace(string(buf[:n]))
+        response := strings.TrimSpace(line)
+        if strings.HasPrefix(response, "view ") {
+            fmt.Print(renderView(response))
+            continue
+        }
         fmt.Println(response)
         if strings.Contains(response, "Login successful") {
             fmt.Println("You have successfully logged in. You can now join the game.")
         }
     }
-}
\ No newline at end of file
+}
+
+// viewLegend explains the marks of a view.
+const viewLegend = "@ you  P player  * wild Pokémon  \" grass  ~ water  # wall  ^ cave  T town  . path"
+
+// renderView draws a view the server sent, "view <x> <y> <row>|<row>|...",
+// as a grid of the tiles around the player.
+func renderView(view string) string {
+    fields := strings.SplitN(view, " ", 4)
+    if len(fields) < 4 {
+        return view + "\n"
+    }
+    rows := strings.Split(fields[3], "|")
+
+    var b strings.Builder
+    fmt.Fprintf(&b, "\nAround you at (%s, %s):\n", fields[1], fields[2])
+    border := "+" + strings.Repeat("-", 2*len(rows[0])+1) + "+\n"
+    b.WriteString(border)
+    for _, row := range rows {
+        b.WriteString("| ")
+        for _, mark := range row {
+            b.WriteRune(mark)
+            b.WriteByte(' ')
+        }
+        b.WriteString("|\n")
+    }
+    b.WriteString(border)
+    b.WriteString(viewLegend + "\n")
+    return b.String()
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "log"
+    "net"
+    "strings"
+    "time"
+
+    "github.com/nguyensngoc108/pokemon-game/battleServer/usermanager"
+    "github.com/nguyensngoc108/pokemon-game/internal/growth"
+    "github.com/nguyensngoc108/pokemon-game/internal/protocol"
+)
+
+// wildUsername is the side of a battle the wild Pokémon plays.
+const wildUsername = "wild"
+
+// textConn lets a pokeCat player take part in a battle run by battleServer's
+// engine. The engine writes battle protocol messages, and textConn writes
+// their text instead, as pokeCat speaks plain text.
+type textConn struct {
+    net.Conn
+}
+
+func (c textConn) Write(b []byte) (int, error) {
+    var msg protocol.Message
+    if err := json.Unmarshal(b, &msg); err != nil {
+        return c.Conn.Write(b)
+    }
+    text := msg.Text
+    if msg.Type == protocol.TypeError {
+        text = msg.Error
+    }
+    if _, err := fmt.Fprintln(c.Conn, strings.TrimRight(text, "\n")); err != nil {
+        return 0, err
+    }
+    return len(b), nil
+}
+
+// SetWriteDeadline does nothing: pokeCat writes to the same connection
+// without deadlines, and one left behind by the engine would break them.
+func (c textConn) SetWriteDeadline(time.Time) error {
+    return nil
+}
+
+// caught returns the Pokémon as the battle engine takes it.
+func (p Pokemon) caught() *usermanager.CaughtPokemon {
+    return &usermanager.CaughtPokemon{Name: p.Name, ElementalMultiplier: p.ElementalMultiplier, Instance: p.Instance}
+}
+
+// Fight starts a battle between the player's lead Pokémon and the wild one
+// they are facing, to weaken it before throwing a ball. The player's input
+// goes to the battle until it is over. A lead that fainted in its last
+// battle has recovered by its next.
+func (gw *GameWorld) Fight(username string) {
+    gw.mu.Lock()
+    defer gw.mu.Unlock()
+
+    player, exists := gw.Players[username]
+    if !exists {
+        fmt.Println("Player not found:", username)
+        return
+    }
+    encounter := player.Encounter
+    if encounter == nil {
+        fmt.Fprintln(player.Conn, "There is no wild Pokémon to fight.")
+        return
+    }
+    if encounter.Battle != nil {
+        fmt.Fprintln(player.Conn, "You are already battling.")
+        return
+    }
+    if len(player.Pokemons) == 0 {
+        fmt.Fprintln(player.Conn, "You have no Pokémon to battle with.")
+        return
+    }
+    // Battle with the Pokémon as battleServer last saved them
+    pokemons, err := loadPokemons(username)
+    if err != nil {
+        log.Println("Error loading players data:", err)
+    } else if len(pokemons) > 0 {
+        player.Pokemons = pokemons
+    }
+    lead := 0
+
+    trainer, err := usermanager.NewPokemonUser(username, player.Pokemons[lead].caught())
+    if err != nil {
+        fmt.Fprintf(player.Conn, "Your %s can't battle: %v\n", player.Pokemons[lead].Name, err)
+        return
+    }
+    wild, err := usermanager.NewPokemonUser(wildName(username), encounter.Pokemon.caught())
+    if err != nil {
+        fmt.Fprintf(player.Conn, "The wild %s can't battle: %v\n", encounter.Pokemon.Name, err)
+        return
+    }
+    trainer.Conn = textConn{player.Conn}
+    wild.Bot = &usermanager.Bot{Difficulty: usermanager.DifficultyRandom}
+
+    battle := usermanager.NewUserManager()
+    battle.KeepHP = true
+    battle.JoinUser(trainer)
+    battle.JoinUser(wild)
+    battle.OnFinish = func(battle *usermanager.UserManager) {
+        gw.finishFight(username, battle)
+    }
+    encounter.Battle, encounter.Fighter = battle, lead
+    go battle.Run()
+}
+
+// wildName is the name the wild Pokémon battles under, which mustn't be the
+// player's own.
+func wildName(username string) string {
+    if username == wildUsername {
+        return wildUsername + " pokemon"
+    }
+    return wildUsername
+}
+
+// BattleCommand passes a player's input to the battle they are in, reporting
+// false if they aren't in one.
+func (gw *GameWorld) BattleCommand(username, command string) bool {
+    gw.mu.Lock()
+    defer gw.mu.Unlock()
+
+    player, exists := gw.Players[username]
+    if !exists || player.Encounter == nil || player.Encounter.Battle == nil {
+        return false
+    }
+    player.Encounter.Battle.Submit(usermanager.Command{Username: username, Name: "move", Arg: command})
+    return true
+}
+
+// finishFight brings what happened in a wild battle back to the pokeCat
+// world: the HP both Pokémon are left with and what the player's Pokémon
+// earned. It is called from the battle's goroutine once the battle is over.
+func (gw *GameWorld) finishFight(username string, battle *usermanager.UserManager) {
+    gw.mu.Lock()
+    defer gw.mu.Unlock()
+
+    player, exists := gw.Players[username]
+    if !exists || player.Encounter == nil || player.Encounter.Battle != battle {
+        return
+    }
+    encounter := player.Encounter
+    encounter.Battle = nil
+
+    fighter := battle.Users[username].PokemonData[0]
+    err := updatePlayerData(player, func() {
+        if encounter.Fighter >= len(player.Pokemons) {
+            return
+        }
+        pokemon := &player.Pokemons[encounter.Fighter]
+        pokemon.Instance = *fighter.Instance
+        if fighter.Caught.Name != pokemon.Name {
+            // It evolved
+            if species, err := growth.LoadSpecies(dataPath, pokemon.SpeciesID); err == nil {
+                pokemon.setSpecies(species.Monster)
+            }
+        }
+    })
+    if err != nil {
+        log.Println("Error saving players data:", err)
+    }
+
+    wild := battle.Users[wildName(username)].PokemonData[0]
+    encounter.Pokemon.CurrentHP = wild.Instance.CurrentHP
+    if encounter.Pokemon.CurrentHP <= 0 {
+        player.Encounter = nil
+        fmt.Fprintf(player.Conn, "The wild %s fainted.\n", encounter.Pokemon.Name)
+    } else {
+        fmt.Fprintf(player.Conn, "The wild %s has %d/%d HP left. Throw a ball or run.\n", encounter.Pokemon.Name, wild.CurrentHP, wild.Instance.Stats(wild.Monster).HP)
+    }
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/nguyensngoc108/pokemon-game/battleServer/usermanager"
+)
+
+// startFight gives ash a lead Pokémon and an encounter with a wild one, and
+// starts a battle between them.
+func startFight(t *testing.T, lead, wild Pokemon) (*GameWorld, *Player, *recordConn, *usermanager.UserManager) {
+    t.Helper()
+    gw := newTestWorld(t, "...\n")
+    player, conn := addTestPlayer(gw, "ash", Position{X: 1, Y: 0})
+    err := updatePlayerData(player, func() {
+        player.Pokemons = []Pokemon{lead}
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    player.Encounter = &Encounter{Pokemon: wild, Position: player.Position}
+
+    gw.Fight("ash")
+    if player.Encounter.Battle == nil {
+        t.Fatalf("no battle was started: %q", conn.String())
+    }
+    return gw, player, conn, player.Encounter.Battle
+}
+
+// waitForFight waits until finishFight has brought the battle's result back
+// to the world.
+func waitForFight(t *testing.T, gw *GameWorld, player *Player, battle *usermanager.UserManager) {
+    t.Helper()
+    select {
+    case <-battle.Done():
+    case <-time.After(10 * time.Second):
+        t.Fatal("the battle never finished")
+    }
+    for deadline := time.Now().Add(10 * time.Second); ; {
+        gw.mu.Lock()
+        finished := player.Encounter == nil || player.Encounter.Battle == nil
+        gw.mu.Unlock()
+        if finished {
+            return
+        }
+        if time.Now().After(deadline) {
+            t.Fatal("finishFight was never called")
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+}
+
+func TestGameWorld_Fight(t *testing.T) {
+    tests := []struct {
+        name      string
+        encounter *Encounter
+        wantText  string
+    }{
+        {name: "no encounter", wantText: "There is no wild Pokémon to fight."},
+        {name: "no Pokémon", encounter: &Encounter{}, wantText: "You have no Pokémon to battle with."},
+        {name: "already battling", encounter: &Encounter{Battle: usermanager.NewUserManager()}, wantText: "You are already battling."},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            gw := newTestWorld(t, "...\n")
+            player, conn := addTestPlayer(gw, "ash", Position{X: 1, Y: 0})
+            player.Encounter = tt.encounter
+            gw.Fight("ash")
+            if !strings.Contains(conn.String(), tt.wantText) {
+                t.Errorf("ash was sent %q, want %q in it", conn.String(), tt.wantText)
+            }
+            // Outside a battle, the player's input is a direction again
+            inBattle := tt.encounter != nil && tt.encounter.Battle != nil
+            if got := gw.BattleCommand("ash", "1"); got != inBattle {
+                t.Errorf("BattleCommand() = %v, want %v", got, inBattle)
+            }
+        })
+    }
+}
+
+func TestGameWorld_finishFight(t *testing.T) {
+    t.Run("wild Pokémon knocked out", func(t *testing.T) {
+        gw := newTestWorld(t, "...\n")
+        lead := wildPokemon(t, gw, 6, 50)
+        lead.CurrentHP = 100
+        wild := wildPokemon(t, gw, 16, 2)
+        gw, player, conn, battle := startFight(t, lead, wild)
+
+        // A level 50 Charizard's Flamethrower knocks out a level 2 Pidgey
+        for !strings.Contains(conn.String(), "Choose your next move") {
+            time.Sleep(10 * time.Millisecond)
+        }
+        if !gw.BattleCommand("ash", "flamethrower") {
+            t.Fatal("BattleCommand() didn't reach the battle")
+        }
+        waitForFight(t, gw, player, battle)
+
+        if player.Encounter != nil {
+            t.Error("the encounter went on after the wild Pokémon fainted")
+        }
+        if want := "The wild Pidgey fainted."; !strings.Contains(conn.String(), want) {
+            t.Errorf("ash was sent %q, want %q in it", conn.String(), want)
+        }
+        saved, err := loadPokemons("ash")
+        if err != nil {
+            t.Fatal(err)
+        }
+        fighter := battle.Users["ash"].PokemonData[0]
+        if saved[0].CurrentHP != fighter.CurrentHP || saved[0].CurrentHP > 100 {
+            t.Errorf("Charizard was saved with %d HP, want the %d it ended the battle on", saved[0].CurrentHP, fighter.CurrentHP)
+        }
+        if saved[0].Experience <= lead.Experience {
+            t.Errorf("Charizard's experience = %d, want more than %d", saved[0].Experience, lead.Experience)
+        }
+    })
+
+    t.Run("player quits", func(t *testing.T) {
+        gw := newTestWorld(t, "...\n")
+        lead := wildPokemon(t, gw, 10, 5)
+        lead.CurrentHP = 7
+        wild := wildPokemon(t, gw, 143, 40)
+        wild.CurrentHP = 30
+        gw, player, conn, battle := startFight(t, lead, wild)
+
+        // Both Pokémon start the battle with the HP they had, and end it
+        // with the same when the player quits straight away
+        for !strings.Contains(conn.String(), "Choose your next move") {
+            time.Sleep(10 * time.Millisecond)
+        }
+        gw.BattleCommand("ash", "quit")
+        waitForFight(t, gw, player, battle)
+
+        if player.Encounter == nil {
+            t.Fatal("the encounter ended with the wild Pokémon still standing")
+        }
+        if got := player.Encounter.Pokemon.CurrentHP; got != 30 {
+            t.Errorf("wild Snorlax has %d HP, want 30", got)
+        }
+        maxHP := wild.Stats(wild.species()).HP
+        if want := fmt.Sprintf("The wild Snorlax has 30/%d HP left. Throw a ball or run.", maxHP); !strings.Contains(conn.String(), want) {
+            t.Errorf("ash was sent %q, want %q in it", conn.String(), want)
+        }
+        saved, err := loadPokemons("ash")
+        if err != nil {
+            t.Fatal(err)
+        }
+        if saved[0].CurrentHP != 7 {
+            t.Errorf("Caterpie was saved with %d HP, want 7", saved[0].CurrentHP)
+        }
+    })
+}
+
+func TestLoadPokemons(t *testing.T) {
+    tests := []struct {
+        name      string
+        players   string
+        wantNames []string
+        wantErr   bool
+    }{
+        {name: "no players file", wantNames: []string{}},
+        {name: "not saved yet", players: `[{"Username": "gary", "Pokemons": [{"name": "Pidgey"}]}]`, wantNames: []string{}},
+        {name: "saved", players: `[{"Username": "ash", "Pokemons": [{"name": "Pidgey"}, {"name": "Rattata"}]}]`, wantNames: []string{"Pidgey", "Rattata"}},
+        // battleServer saves the name of a Pokémon that evolved, but not its
+        // new base stats
+        {name: "evolved in battleServer", players: `[{"Username": "ash", "Pokemons": [{"name": "Ivysaur", "hp": 45, "species_id": 2}]}]`, wantNames: []string{"Ivysaur"}},
+        {name: "unreadable", players: `{`, wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            newTestWorld(t, "...\n")
+            if tt.players == "" {
+                os.Remove(playersFile)
+            } else if err := os.WriteFile(playersFile, []byte(tt.players), 0644); err != nil {
+                t.Fatal(err)
+            }
+
+            pokemons, err := loadPokemons("ash")
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("loadPokemons() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if tt.wantErr {
+                return
+            }
+            var names []string
+            for _, pokemon := range pokemons {
+                names = append(names, pokemon.Name)
+                if pokemon.SpeciesID == 2 && pokemon.HP != 60 {
+                    t.Errorf("Ivysaur has %d base HP, want 60", pokemon.HP)
+                }
+            }
+            if strings.Join(names, ",") != strings.Join(tt.wantNames, ",") || pokemons == nil {
+                t.Errorf("loadPokemons() = %v, want %v", names, tt.wantNames)
+            }
+        })
+    }
+}
+
+func TestGameWorld_AddPlayer(t *testing.T) {
+    gw := newTestWorld(t, "...\n")
+    if err := os.WriteFile(playersFile, []byte(`{`), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := gw.AddPlayer("ash", "1", &recordConn{}); err == nil {
+        t.Error("AddPlayer() with an unreadable players file succeeded")
+    }
+    if len(gw.Players) != 0 {
+        t.Errorf("AddPlayer() failed but left %d players in the world", len(gw.Players))
+    }
+}
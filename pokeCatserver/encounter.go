@@ -0,0 +1,119 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "strings"
+
+    "github.com/nguyensngoc108/pokemon-game/battleServer/usermanager"
+    "github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+// Encounter is a wild Pokemon a player has stepped onto. The player stays
+// put until they catch it, run, or knock it out in a battle.
+type Encounter struct {
+    Pokemon  Pokemon
+    Position Position
+    // Battle is the battle with the wild Pokemon, if the player is fighting
+    // it, and Fighter the number of the player's Pokemon battling it.
+    Battle  *usermanager.UserManager
+    Fighter int
+}
+
+// startEncounter takes the wild Pokemon at the player's position off the map
+// and starts an encounter with it. Callers hold the world lock.
+func (gw *GameWorld) startEncounter(player *Player, pokemon Pokemon) {
+    if len(player.Pokemons) >= maxPokemons {
+        fmt.Fprintf(player.Conn, "A wild %s is here, but you can't carry any more Pokémon.\n", pokemon.Name)
+        return
+    }
+    delete(gw.Pokemons, player.Position)
+    player.Encounter = &Encounter{Pokemon: pokemon, Position: player.Position}
+    fmt.Fprintf(player.Conn, "A wild %s (level %d) appeared! Throw a ball with 'throw <%s>', 'fight' it or 'run'.\n", pokemon.Name, pokemon.Level, strings.Join(models.BallNames, "|"))
+}
+
+// ThrowBall throws a ball at the wild Pokemon the player is encountering and
+// tells them whether it was caught.
+func (gw *GameWorld) ThrowBall(username, ballName string) {
+    gw.mu.Lock()
+    defer gw.mu.Unlock()
+
+    player, exists := gw.Players[username]
+    if !exists {
+        fmt.Println("Player not found:", username)
+        return
+    }
+    encounter := player.Encounter
+    if encounter == nil {
+        fmt.Fprintln(player.Conn, "There is no wild Pokémon to throw a ball at.")
+        return
+    }
+    if encounter.Battle != nil {
+        fmt.Fprintln(player.Conn, "You can't throw a ball in the middle of a battle. Choose a move, or 'quit' the battle.")
+        return
+    }
+    ball, ok := models.FindBall(ballName)
+    if !ok {
+        fmt.Fprintf(player.Conn, "There is no %q ball. Throw one of: %s.\n", ballName, strings.Join(models.BallNames, ", "))
+        return
+    }
+
+    wild := encounter.Pokemon
+    species := wild.species()
+    maxHP := wild.Stats(species).HP
+    currentHP := wild.CurrentHP
+    if currentHP <= 0 {
+        currentHP = maxHP
+    }
+    shakes, caught := models.ThrowBall(models.CatchRate(species), currentHP, maxHP, ball, gw.rng)
+
+    fmt.Fprintf(player.Conn, "You threw a %s!\n", ball.Name)
+    for i := 0; i < shakes; i++ {
+        fmt.Fprintln(player.Conn, "The ball shook...")
+    }
+    if !caught {
+        fmt.Fprintf(player.Conn, "Oh no! The wild %s broke free!\n", wild.Name)
+        return
+    }
+    player.Encounter = nil
+    fmt.Fprintf(player.Conn, "Gotcha! %s was caught!\n", wild.Name)
+    fmt.Printf("%s captured a %s\n", username, wild.Name)
+    err := updatePlayerData(player, func() {
+        player.Pokemons = append(player.Pokemons, wild)
+        rewardCatch(player, wild)
+    })
+    if err != nil {
+        log.Println("Error saving players data:", err)
+        fmt.Fprintf(player.Conn, "%s couldn't be sent to your collection, sorry.\n", wild.Name)
+    }
+}
+
+// Run ends the player's encounter, leaving the wild Pokemon where it was.
+func (gw *GameWorld) Run(username string) {
+    var views []pendingView
+    // Deferred before the unlock, so the views are sent after it
+    defer func() { sendViews(views) }()
+    gw.mu.Lock()
+    defer gw.mu.Unlock()
+
+    player, exists := gw.Players[username]
+    if !exists {
+        fmt.Println("Player not found:", username)
+        return
+    }
+    encounter := player.Encounter
+    if encounter == nil {
+        fmt.Fprintln(player.Conn, "There is nothing to run from.")
+        return
+    }
+    if encounter.Battle != nil {
+        fmt.Fprintln(player.Conn, "You can't run in the middle of a battle. Choose a move, or 'quit' the battle.")
+        return
+    }
+    player.Encounter = nil
+    if _, taken := gw.Pokemons[encounter.Position]; !taken {
+        gw.Pokemons[encounter.Position] = encounter.Pokemon
+    }
+    fmt.Fprintln(player.Conn, "Got away safely!")
+    views = gw.views(encounter.Position)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+    "bytes"
+    "math/rand"
+    "net"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+
+    "github.com/nguyensngoc108/pokemon-game/battleServer/usermanager"
+    "github.com/nguyensngoc108/pokemon-game/internal/growth"
+)
+
+// recordConn keeps everything the server writes to a player. Battles write
+// from their own goroutine, so it is safe to use from several.
+type recordConn struct {
+    net.Conn
+    mu   sync.Mutex
+    sent bytes.Buffer
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.sent.Write(b)
+}
+
+func (c *recordConn) String() string {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.sent.String()
+}
+
+// newTestWorld makes a world from a tile map, with ash saved in a players
+// file of its own.
+func newTestWorld(t *testing.T, tiles string) *GameWorld {
+    t.Helper()
+    world, err := parseWorldMap(tiles)
+    if err != nil {
+        t.Fatal(err)
+    }
+    playersFile = filepath.Join(t.TempDir(), "players.json")
+    t.Cleanup(func() { playersFile = "players.json" })
+    if err := os.WriteFile(playersFile, []byte(`[{"Username": "ash", "Password": "1", "Pokemons": []}]`), 0644); err != nil {
+        t.Fatal(err)
+    }
+    gw := NewGameWorld(world, nil)
+    gw.rng = rand.New(rand.NewSource(1))
+    return gw
+}
+
+// addTestPlayer puts a player in the world.
+func addTestPlayer(gw *GameWorld, username string, pos Position) (*Player, *recordConn) {
+    conn := &recordConn{}
+    player := &Player{Username: username, Conn: conn, Position: pos, Pokemons: []Pokemon{}}
+    gw.Players[username] = player
+    return player, conn
+}
+
+// wildPokemon makes a wild Pokémon of a species by national id.
+func wildPokemon(t *testing.T, gw *GameWorld, id, level int) Pokemon {
+    t.Helper()
+    species, err := growth.LoadSpecies(dataPath, id)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return newWildPokemon(species.Monster, level, gw.rng)
+}
+
+func TestGameWorld_MovePlayer_encounter(t *testing.T) {
+    tests := []struct {
+        name          string
+        carrying      int
+        wantEncounter bool
+        wantText      string
+    }{
+        {name: "stepping onto a wild Pokémon", wantEncounter: true, wantText: "A wild Pidgey (level 5) appeared!"},
+        {name: "carrying too many", carrying: maxPokemons, wantText: "A wild Pidgey is here, but you can't carry any more Pokémon."},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            gw := newTestWorld(t, "...\n")
+            player, conn := addTestPlayer(gw, "ash", Position{X: 0, Y: 0})
+            player.Pokemons = make([]Pokemon, tt.carrying)
+            gw.Pokemons[Position{X: 1, Y: 0}] = wildPokemon(t, gw, 16, 5)
+
+            gw.MovePlayer("ash", "right")
+            if player.Position != (Position{X: 1, Y: 0}) {
+                t.Errorf("player is at %+v, want them on the wild Pokémon", player.Position)
+            }
+            if got := player.Encounter != nil; got != tt.wantEncounter {
+                t.Errorf("encounter started = %v, want %v", got, tt.wantEncounter)
+            }
+            // A Pokémon being encountered is taken off the map
+            if _, onMap := gw.Pokemons[Position{X: 1, Y: 0}]; onMap == tt.wantEncounter {
+                t.Errorf("wild Pokémon on the map = %v, want %v", onMap, !tt.wantEncounter)
+            }
+            if !strings.Contains(conn.String(), tt.wantText) {
+                t.Errorf("ash was sent %q, want %q in it", conn.String(), tt.wantText)
+            }
+        })
+    }
+
+    // Players stay put while they face a wild Pokémon
+    gw := newTestWorld(t, "...\n")
+    player, conn := addTestPlayer(gw, "ash", Position{X: 1, Y: 0})
+    player.Encounter = &Encounter{Pokemon: wildPokemon(t, gw, 16, 5), Position: player.Position}
+    gw.MovePlayer("ash", "right")
+    if player.Position != (Position{X: 1, Y: 0}) {
+        t.Errorf("player moved to %+v during an encounter", player.Position)
+    }
+    if want := "You are facing a wild Pidgey. Throw a ball, fight or run."; !strings.Contains(conn.String(), want) {
+        t.Errorf("ash was sent %q, want %q in it", conn.String(), want)
+    }
+}
+
+func TestGameWorld_ThrowBall(t *testing.T) {
+    tests := []struct {
+        name          string
+        species       int
+        encounter     bool
+        battling      bool
+        ball          string
+        wantEncounter bool
+        wantCaught    bool
+        wantText      string
+    }{
+        {name: "no encounter", ball: "poke", wantText: "There is no wild Pokémon to throw a ball at."},
+        {name: "unknown ball", species: 16, encounter: true, ball: "rock", wantEncounter: true, wantText: `There is no "rock" ball.`},
+        {name: "in a battle", species: 16, encounter: true, battling: true, ball: "poke", wantEncounter: true, wantText: "You can't throw a ball in the middle of a battle."},
+        {name: "caught", species: 16, encounter: true, ball: "master", wantCaught: true, wantText: "Gotcha! Pidgey was caught!"},
+        // Mewtwo at full HP is all but impossible to catch in a Poké Ball
+        {name: "broke free", species: 150, encounter: true, ball: "poke", wantEncounter: true, wantText: "Oh no! The wild Mewtwo broke free!"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            gw := newTestWorld(t, "...\n")
+            player, conn := addTestPlayer(gw, "ash", Position{X: 1, Y: 0})
+            if tt.encounter {
+                player.Encounter = &Encounter{Pokemon: wildPokemon(t, gw, tt.species, 5), Position: player.Position}
+            }
+            if tt.battling {
+                player.Encounter.Battle = usermanager.NewUserManager()
+            }
+
+            gw.ThrowBall("ash", tt.ball)
+            if got := player.Encounter != nil; got != tt.wantEncounter {
+                t.Errorf("encounter still on = %v, want %v", got, tt.wantEncounter)
+            }
+            if !strings.Contains(conn.String(), tt.wantText) {
+                t.Errorf("ash was sent %q, want %q in it", conn.String(), tt.wantText)
+            }
+
+            // A caught Pokémon joins the player's saved collection
+            caught := len(player.Pokemons) == 1 && player.Pokemons[0].Name == "Pidgey"
+            if caught != tt.wantCaught {
+                t.Errorf("player has %d Pokémon, want Pidgey caught = %v", len(player.Pokemons), tt.wantCaught)
+            }
+            saved, err := loadPokemons("ash")
+            if err != nil {
+                t.Fatal(err)
+            }
+            if got := len(saved) == 1; got != tt.wantCaught {
+                t.Errorf("ash has %d saved Pokémon, want Pidgey caught = %v", len(saved), tt.wantCaught)
+            }
+        })
+    }
+}
+
+func TestGameWorld_Run(t *testing.T) {
+    tests := []struct {
+        name          string
+        encounter     bool
+        battling      bool
+        respawned     bool
+        wantEncounter bool
+        wantWild      string
+        wantText      string
+    }{
+        {name: "no encounter", wantText: "There is nothing to run from."},
+        {name: "in a battle", encounter: true, battling: true, wantEncounter: true, wantText: "You can't run in the middle of a battle."},
+        // The wild Pokémon is left where it was
+        {name: "got away", encounter: true, wantWild: "Pidgey", wantText: "Got away safely!"},
+        // unless another has spawned there meanwhile
+        {name: "spot taken", encounter: true, respawned: true, wantWild: "Rattata", wantText: "Got away safely!"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            gw := newTestWorld(t, "...\n")
+            pos := Position{X: 1, Y: 0}
+            player, conn := addTestPlayer(gw, "ash", pos)
+            if tt.encounter {
+                player.Encounter = &Encounter{Pokemon: wildPokemon(t, gw, 16, 5), Position: pos}
+            }
+            if tt.battling {
+                player.Encounter.Battle = usermanager.NewUserManager()
+            }
+            if tt.respawned {
+                gw.Pokemons[pos] = wildPokemon(t, gw, 19, 3)
+            }
+
+            gw.Run("ash")
+            if got := player.Encounter != nil; got != tt.wantEncounter {
+                t.Errorf("encounter still on = %v, want %v", got, tt.wantEncounter)
+            }
+            if got := gw.Pokemons[pos].Name; got != tt.wantWild {
+                t.Errorf("wild Pokémon on the map = %q, want %q", got, tt.wantWild)
+            }
+            if !strings.Contains(conn.String(), tt.wantText) {
+                t.Errorf("ash was sent %q, want %q in it", conn.String(), tt.wantText)
+            }
+        })
+    }
+}
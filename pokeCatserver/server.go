@@ -2,6 +2,8 @@ package main
 
 import (
     "encoding/json"
+    "errors"
+    "flag"
     "fmt"
     "log"
     "math/rand"
@@ -10,15 +12,20 @@ import (
     "strings"
     "sync"
     "time"
+
+    "github.com/nguyensngoc108/pokemon-game/internal/growth"
+    "github.com/nguyensngoc108/pokemon-game/internal/models"
+    "github.com/nguyensngoc108/pokemon-game/internal/playerfile"
 )
 
 const (
-    worldSize       = 1000
-    maxPokemons     = 200
-    spawnInterval   = time.Minute
-    despawnInterval = 5 * time.Minute
+    maxPokemons = 200
+    dataPath    = "../internal/models"
 )
 
+// playersFile is where players and the Pokémon they caught are saved.
+var playersFile = "players.json"
+
 type Position struct {
     X, Y int
 }
@@ -31,160 +38,235 @@ type Pokemon struct {
     SpecialAttack     int      `json:"sp_atk"`
     SpecialDefense    int      `json:"sp_def"`
     Speed             int      `json:"speed"`
+    CatchRate         int      `json:"catch_rate"`
     ElementalMultiplier float64 `json:"elemental_multiplier"`
-    Level             int      `json:"level"`
     Abilities         []string `json:"abilities"`
     Types             []string `json:"types"`
+    // The individual: level, IVs, EVs, nature, HP, moves and experience.
+    // The stats above are its species' base stats.
+    models.Instance
 }
 
 type Player struct {
     Username string
     Password string
-    Conn     net.Conn
+    Conn     net.Conn `json:"-"`
     Position Position
     Pokemons []Pokemon
+    // Encounter is the wild Pokemon the player is trying to catch, if any.
+    Encounter *Encounter `json:"-"`
 }
 
 type GameWorld struct {
     Players  map[string]*Player
     Pokemons map[Position]Pokemon
+    world    *WorldMap
+    spawns   *SpawnTable
+    rng      *rand.Rand
     mu       sync.Mutex
 }
 
-func NewGameWorld() *GameWorld {
+func NewGameWorld(world *WorldMap, spawns *SpawnTable) *GameWorld {
     return &GameWorld{
         Players:  make(map[string]*Player),
         Pokemons: make(map[Position]Pokemon),
+        world:    world,
+        spawns:   spawns,
+        rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
     }
 }
 
-func (gw *GameWorld) AddPlayer(username, password string, conn net.Conn) *Player {
-    gw.mu.Lock()
-    defer gw.mu.Unlock()
+func (gw *GameWorld) AddPlayer(username, password string, conn net.Conn) (*Player, error) {
+    pokemons, err := loadPokemons(username)
+    if err != nil {
+        return nil, err
+    }
 
+    gw.mu.Lock()
     player := &Player{
         Username: username,
         Password: password,
         Conn:     conn,
-        Position: Position{
-            X: rand.Intn(worldSize),
-            Y: rand.Intn(worldSize),
-        },
-        Pokemons: []Pokemon{},
+        Position: gw.world.StartPosition(gw.rng),
+        Pokemons: pokemons,
     }
     gw.Players[username] = player
-    return player
+    views := gw.views(player.Position)
+    gw.mu.Unlock()
+
+    sendViews(views)
+    return player, nil
 }
 
-func (gw *GameWorld) MovePlayer(username, direction string) {
+// Player returns the player with a username, or nil if they haven't joined.
+func (gw *GameWorld) Player(username string) *Player {
+    gw.mu.Lock()
+    defer gw.mu.Unlock()
+
+    return gw.Players[username]
+}
+
+// MovePlayer moves a player a step in a direction, reporting whether they
+// moved. Players tell why they can't.
+func (gw *GameWorld) MovePlayer(username, direction string) bool {
+    var views []pendingView
+    // Deferred before the unlock, so the views are sent after it
+    defer func() { sendViews(views) }()
     gw.mu.Lock()
     defer gw.mu.Unlock()
 
     player, exists := gw.Players[username]
     if !exists {
-        fmt.Println("Player not found:", username)
-        return
+        log.Println("Player not found:", username)
+        return false
+    }
+
+    if player.Encounter != nil {
+        fmt.Fprintf(player.Conn, "You are facing a wild %s. Throw a ball, fight or run.\n", player.Encounter.Pokemon.Name)
+        return false
     }
 
+    next := player.Position
     switch direction {
     case "up":
-        if player.Position.Y > 0 {
-            player.Position.Y--
-        }
+        next.Y--
     case "down":
-        if player.Position.Y < worldSize-1 {
-            player.Position.Y++
-        }
+        next.Y++
     case "left":
-        if player.Position.X > 0 {
-            player.Position.X--
-        }
+        next.X--
     case "right":
-        if player.Position.X < worldSize-1 {
-            player.Position.X++
-        }
+        next.X++
+    default:
+        fmt.Fprintf(player.Conn, "You can't move %q. Move up, down, left or right.\n", direction)
+        return false
+    }
+    if tile := gw.world.Tile(next); !tile.Walkable() {
+        fmt.Fprintln(player.Conn, blockedBy[tile])
+        return false
     }
+    previous := player.Position
+    player.Position = next
 
-    // Check for Pokémon capture
+    // Stepping onto a wild Pokémon starts an encounter with it
     if pokemon, exists := gw.Pokemons[player.Position]; exists {
-        if len(player.Pokemons) < maxPokemons {
-            player.Pokemons = append(player.Pokemons, pokemon)
-            delete(gw.Pokemons, player.Position)
-            fmt.Printf("%s captured a %s\n", username, pokemon.Name)
-            savePlayerData(player)
-        }
+        gw.startEncounter(player, pokemon)
     }
+    views = gw.views(previous, next)
+    return true
 }
 
-func (gw *GameWorld) SpawnPokemons() {
+// SpawnPokemons spawns a batch of wild Pokémon from the spawn table every
+// interval, each on a tile whose biome suits its types.
+func (gw *GameWorld) SpawnPokemons(batch int, interval time.Duration) {
     for {
-        time.Sleep(spawnInterval)
+        time.Sleep(interval)
         gw.mu.Lock()
-        for i := 0; i < 50; i++ {
-            pos := Position{
-                X: rand.Intn(worldSize),
-                Y: rand.Intn(worldSize),
+        var spawned []Position
+        for i := 0; i < batch; i++ {
+            species, level := gw.spawns.Pick(gw.rng)
+            pos, ok := gw.world.SpawnPosition(species, gw.rng)
+            if !ok {
+                continue
             }
-            pokemonName := getRandomPokemonName()
-            pokemon, err := loadPokemonData(pokemonName)
-            if err != nil {
-                fmt.Println("Error loading Pokémon data:", err)
+            if _, taken := gw.Pokemons[pos]; taken {
                 continue
             }
-            pokemon.ElementalMultiplier = 0.5 + rand.Float64()*0.5
-            pokemon.Level = rand.Intn(100) + 1
-            gw.Pokemons[pos] = pokemon
+            gw.Pokemons[pos] = newWildPokemon(species, level, gw.rng)
+            spawned = append(spawned, pos)
         }
+        views := gw.views(spawned...)
         gw.mu.Unlock()
+        sendViews(views)
     }
 }
 
-func (gw *GameWorld) DespawnPokemons() {
-    for {
-        time.Sleep(despawnInterval)
-        gw.mu.Lock()
-        for pos := range gw.Pokemons {
-            delete(gw.Pokemons, pos)
-        }
-        gw.mu.Unlock()
+// species returns the species data the Pokemon was loaded with.
+func (p Pokemon) species() *models.Monster {
+    return &models.Monster{
+        NationalID: p.SpeciesID,
+        Name:       p.Name,
+        HP:         p.HP,
+        Attack:     p.Attack,
+        Defense:    p.Defense,
+        SpAtk:      p.SpecialAttack,
+        SpDef:      p.SpecialDefense,
+        Speed:      p.Speed,
+        CatchRate:  p.CatchRate,
+        Abilities:  p.Abilities,
+        Types:      p.Types,
     }
 }
 
-func getRandomPokemonName() string {
-    pokemonNames := loadPokemonNames()
-    return pokemonNames[rand.Intn(len(pokemonNames))]
+// setSpecies makes the Pokemon one of a species, as when it evolves.
+func (p *Pokemon) setSpecies(species *models.Monster) {
+    p.Name = species.Name
+    p.HP = species.HP
+    p.Attack = species.Attack
+    p.Defense = species.Defense
+    p.SpecialAttack = species.SpAtk
+    p.SpecialDefense = species.SpDef
+    p.Speed = species.Speed
+    p.CatchRate = species.CatchRate
+    p.Abilities = species.Abilities
+    p.Types = species.Types
 }
 
-func loadPokemonNames() []string {
-    var pokemonNames []string
-    data, err := os.ReadFile("../internal/models/pokemonNames.json")
-    if err != nil {
-        log.Fatal("Error loading Pokémon names:", err)
-    }
-    err = json.Unmarshal(data, &pokemonNames)
-    if err != nil {
-        log.Fatal("Error unmarshalling Pokémon names:", err)
+// syncSpecies brings the species fields of saved Pokémon up to date with
+// their SpeciesID. battleServer saves only the name and individual data of
+// Pokémon that grow in its battles, so one that evolved there still has its
+// old species' base stats in players.json.
+func syncSpecies(pokemons []Pokemon) {
+    for i := range pokemons {
+        pokemon := &pokemons[i]
+        if pokemon.SpeciesID == 0 {
+            continue
+        }
+        species, err := growth.LoadSpecies(dataPath, pokemon.SpeciesID)
+        if err != nil {
+            log.Printf("Error loading species of %s: %v", pokemon.Name, err)
+            continue
+        }
+        pokemon.setSpecies(species.Monster)
     }
-    return pokemonNames
 }
 
-func loadPokemonData(name string) (Pokemon, error) {
-    var pokemon Pokemon
-    filePath := fmt.Sprintf("../internal/models/monsters/data/%s.json", name)
-    data, err := os.ReadFile(filePath)
-    if err != nil {
-        return pokemon, err
+// rewardCatch gives the player's lead Pokemon experience for a catch, as if
+// it had defeated the caught Pokemon, and tells the player how it grew.
+func rewardCatch(player *Player, caught Pokemon) {
+    if len(player.Pokemons) < 2 {
+        return
     }
-    err = json.Unmarshal(data, &pokemon)
+    lead := &player.Pokemons[0]
+    species, messages, err := growth.Gain(dataPath, &lead.Instance, models.ExperienceYield(caught.species(), caught.Level))
     if err != nil {
-        return pokemon, err
+        fmt.Printf("Error growing %s's %s: %v\n", player.Username, lead.Name, err)
+        return
+    }
+    lead.setSpecies(species.Monster)
+    for _, message := range messages {
+        fmt.Fprintln(player.Conn, message)
+    }
+}
+
+// DespawnPokemons clears the wild Pokémon off the map every interval.
+func (gw *GameWorld) DespawnPokemons(interval time.Duration) {
+    for {
+        time.Sleep(interval)
+        gw.mu.Lock()
+        var despawned []Position
+        for pos := range gw.Pokemons {
+            delete(gw.Pokemons, pos)
+            despawned = append(despawned, pos)
+        }
+        views := gw.views(despawned...)
+        gw.mu.Unlock()
+        sendViews(views)
     }
-    return pokemon, nil
 }
 
 func authenticatePlayer(username, password string) bool {
     var players []Player
-    data, err := os.ReadFile("players.json")
+    data, err := os.ReadFile(playersFile)
     if err != nil {
         log.Fatal("Error loading players data:", err)
     }
@@ -200,30 +282,55 @@ func authenticatePlayer(username, password string) bool {
     return false
 }
 
-func savePlayerData(player *Player) {
+// loadPokemons returns the Pokémon a player caught in earlier games. With no
+// players.json yet, nobody has caught anything.
+func loadPokemons(username string) ([]Pokemon, error) {
     var players []Player
-    data, err := os.ReadFile("players.json")
+    data, err := os.ReadFile(playersFile)
+    if errors.Is(err, os.ErrNotExist) {
+        return []Pokemon{}, nil
+    }
     if err != nil {
-        log.Fatal("Error loading players data:", err)
+        return nil, fmt.Errorf("loading players data: %w", err)
     }
     err = json.Unmarshal(data, &players)
     if err != nil {
-        log.Fatal("Error unmarshalling players data:", err)
+        return nil, fmt.Errorf("unmarshalling players data: %w", err)
     }
-    for i, p := range players {
-        if p.Username == player.Username {
-            players[i] = *player
-            break
+    for _, player := range players {
+        if player.Username == username && player.Pokemons != nil {
+            syncSpecies(player.Pokemons)
+            return player.Pokemons, nil
         }
     }
-    data, err = json.MarshalIndent(players, "", "  ")
-    if err != nil {
-        log.Fatal("Error marshalling players data:", err)
-    }
-    err = os.WriteFile("players.json", data, 0644)
-    if err != nil {
-        log.Fatal("Error saving players data:", err)
-    }
+    return []Pokemon{}, nil
+}
+
+// updatePlayerData changes a player's saved data. battleServer saves the
+// Pokémon that grow in its battles to players.json too, so with the file
+// locked the player's Pokémon are reloaded from it, then changed and saved,
+// and nothing either server saved is lost.
+func updatePlayerData(player *Player, change func()) error {
+    return playerfile.Update(playersFile, func(data []byte) ([]byte, error) {
+        var players []Player
+        err := json.Unmarshal(data, &players)
+        if err != nil {
+            return nil, fmt.Errorf("unmarshalling players data: %w", err)
+        }
+        for i, p := range players {
+            if p.Username != player.Username {
+                continue
+            }
+            if p.Pokemons != nil {
+                syncSpecies(p.Pokemons)
+                player.Pokemons = p.Pokemons
+            }
+            change()
+            players[i] = *player
+            return json.MarshalIndent(players, "", "  ")
+        }
+        return nil, fmt.Errorf("player %s not found", player.Username)
+    })
 }
 
 func startTCPServer(gw *GameWorld) {
@@ -255,7 +362,7 @@ func handleConnection(conn net.Conn, gw *GameWorld) {
             return
         }
 
-        data := string(buf[:n])
+        data := strings.TrimSpace(string(buf[:n]))
         parts := strings.Split(data, " ")
         if len(parts) < 3 {
             continue
@@ -277,9 +384,14 @@ func handleConnection(conn net.Conn, gw *GameWorld) {
 
         switch action {
         case "join":
-            player := gw.Players[username]
+            player := gw.Player(username)
             if player == nil {
-                player = gw.AddPlayer(username, password, conn)
+                player, err = gw.AddPlayer(username, password, conn)
+                if err != nil {
+                    log.Println("Error adding player:", err)
+                    fmt.Fprintf(conn, "Could not load player %s, try again later\n", username)
+                    continue
+                }
                 fmt.Fprintf(conn, "Player %s joined at position (%d, %d)\n", player.Username, player.Position.X, player.Position.Y)
             } else {
                 fmt.Fprintf(conn, "Player %s is already in the game\n", player.Username)
@@ -288,24 +400,60 @@ func handleConnection(conn net.Conn, gw *GameWorld) {
             if len(parts) < 4 {
                 continue
             }
-            direction := parts[3]
-            gw.MovePlayer(username, direction)
-            player := gw.Players[username]
+            // While battling, the player's input is their battle move
+            if gw.BattleCommand(username, strings.Join(parts[3:], " ")) {
+                continue
+            }
+            player := gw.Player(username)
             if player == nil {
-                fmt.Println("Player not found:", username)
-                return
+                log.Println("Player not found:", username)
+                fmt.Fprintf(conn, "Player %s hasn't joined the game yet, join first\n", username)
+                continue
             }
-            fmt.Fprintf(conn, "Player %s moved to position (%d, %d)\n", player.Username, player.Position.X, player.Position.Y)
+            direction := parts[3]
+            if gw.MovePlayer(username, direction) {
+                fmt.Fprintf(conn, "Player %s moved to position (%d, %d)\n", player.Username, player.Position.X, player.Position.Y)
+            }
+        case "throw":
+            ball := "poke"
+            if len(parts) > 3 {
+                ball = strings.Join(parts[3:], " ")
+            }
+            gw.ThrowBall(username, ball)
+        case "run":
+            gw.Run(username)
+        case "fight":
+            gw.Fight(username)
         }
     }
 }
 
 func main() {
+    worldMap := flag.String("map", "world.txt", "map file of the world's tiles: . path, # wall, ~ water, \" grass, ^ cave, T town")
+    spawnTable := flag.String("spawns", "spawns.json", "JSON file of species' spawn weights and levels, or empty to spawn every species by its strength")
+    spawnBatch := flag.Int("spawn-batch", 50, "how many wild Pokémon spawn at a time")
+    spawnInterval := flag.Duration("spawn-interval", time.Minute, "how often wild Pokémon spawn")
+    despawnInterval := flag.Duration("despawn-interval", 5*time.Minute, "how often the wild Pokémon are cleared off the map")
+    flag.Parse()
+
+    world, err := LoadWorldMap(*worldMap)
+    if err != nil {
+        log.Fatal("Error loading world map: ", err)
+    }
+    catalog, err := loadCatalog(dataPath)
+    if err != nil {
+        log.Fatal("Error loading Pokémon data: ", err)
+    }
+    spawns, err := LoadSpawnTable(*spawnTable, catalog)
+    if err != nil {
+        log.Fatal("Error loading spawn table: ", err)
+    }
+
     rand.Seed(time.Now().UnixNano())
-    gameWorld := NewGameWorld()
+    gameWorld := NewGameWorld(world, spawns)
 
-    go gameWorld.SpawnPokemons()
-    go gameWorld.DespawnPokemons()
+    go gameWorld.SpawnPokemons(*spawnBatch, *spawnInterval)
+    go gameWorld.DespawnPokemons(*despawnInterval)
 
     startTCPServer(gameWorld)
 }
\ No newline at end of file
@@ -0,0 +1,163 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "math/rand"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+// loadCatalog reads every species in the data directory once, so spawning
+// doesn't go back to disk. The species are in national id order.
+func loadCatalog(dataPath string) ([]*models.Monster, error) {
+    files, err := filepath.Glob(filepath.Join(dataPath, "monsters", "data", "*.json"))
+    if err != nil {
+        return nil, err
+    }
+    var catalog []*models.Monster
+    for _, file := range files {
+        data, err := os.ReadFile(file)
+        if err != nil {
+            return nil, err
+        }
+        var monster struct {
+            Monster *models.Monster `json:"monster"`
+        }
+        if err := json.Unmarshal(data, &monster); err != nil {
+            return nil, fmt.Errorf("%s: %v", file, err)
+        }
+        if monster.Monster == nil {
+            return nil, fmt.Errorf("%s has no monster data", file)
+        }
+        catalog = append(catalog, monster.Monster)
+    }
+    if len(catalog) == 0 {
+        return nil, fmt.Errorf("no species in %s", dataPath)
+    }
+    sort.Slice(catalog, func(i, j int) bool {
+        return catalog[i].NationalID < catalog[j].NationalID
+    })
+    return catalog, nil
+}
+
+// SpawnEntry sets how often a species spawns and at which levels. Species
+// with no entry spawn by defaultSpawn.
+type SpawnEntry struct {
+    Species string `json:"species"`
+    // Weight is how likely the species is to be picked for a spawn, against
+    // the weights of the others. 0 keeps it from spawning.
+    Weight   int `json:"weight"`
+    MinLevel int `json:"min_level"`
+    MaxLevel int `json:"max_level"`
+}
+
+// defaultSpawn is how a species spawns when the spawn table doesn't say:
+// the stronger it is, the rarer it is and the higher its level, so early
+// species are common at low levels and legendaries rare at high ones.
+func defaultSpawn(species *models.Monster) SpawnEntry {
+    level := max(2, min(60, (models.BaseStats(species).Total()-200)/10))
+    return SpawnEntry{
+        Species:  species.Name,
+        Weight:   models.CatchRate(species),
+        MinLevel: level,
+        MaxLevel: level + 10,
+    }
+}
+
+// spawn is a species that can spawn, with its entry.
+type spawn struct {
+    species *models.Monster
+    entry   SpawnEntry
+}
+
+// SpawnTable picks the species and levels of wild Pokémon.
+type SpawnTable struct {
+    spawns []spawn
+    // cumulative holds the running total of the spawns' weights, to pick
+    // from by a single roll.
+    cumulative []int
+}
+
+// NewSpawnTable builds a spawn table over the catalog's species, taking
+// their weights and levels from the entries and defaultSpawn otherwise.
+func NewSpawnTable(catalog []*models.Monster, entries []SpawnEntry) (*SpawnTable, error) {
+    byName := make(map[string]SpawnEntry, len(entries))
+    for _, entry := range entries {
+        name := strings.ToLower(entry.Species)
+        switch {
+        case entry.Weight < 0:
+            return nil, fmt.Errorf("%s: the weight can't be %d", entry.Species, entry.Weight)
+        case entry.MinLevel < 1 || entry.MaxLevel > models.MaxLevel || entry.MinLevel > entry.MaxLevel:
+            return nil, fmt.Errorf("%s: levels %d to %d aren't between 1 and %d", entry.Species, entry.MinLevel, entry.MaxLevel, models.MaxLevel)
+        }
+        if _, duplicate := byName[name]; duplicate {
+            return nil, fmt.Errorf("%s has two entries", entry.Species)
+        }
+        byName[name] = entry
+    }
+
+    table := &SpawnTable{}
+    total := 0
+    for _, species := range catalog {
+        name := strings.ToLower(species.Name)
+        entry, ok := byName[name]
+        if !ok {
+            entry = defaultSpawn(species)
+        }
+        delete(byName, name)
+        if entry.Weight == 0 {
+            continue
+        }
+        total += entry.Weight
+        table.spawns = append(table.spawns, spawn{species: species, entry: entry})
+        table.cumulative = append(table.cumulative, total)
+    }
+    for _, entry := range byName {
+        return nil, fmt.Errorf("there is no species %s", entry.Species)
+    }
+    if total == 0 {
+        return nil, fmt.Errorf("no species can spawn")
+    }
+    return table, nil
+}
+
+// LoadSpawnTable reads the spawn table's entries from a JSON file, or uses
+// defaultSpawn for every species if path is empty.
+func LoadSpawnTable(path string, catalog []*models.Monster) (*SpawnTable, error) {
+    var entries []SpawnEntry
+    if path != "" {
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return nil, err
+        }
+        if err := json.Unmarshal(data, &entries); err != nil {
+            return nil, fmt.Errorf("%s: %v", path, err)
+        }
+    }
+    table, err := NewSpawnTable(catalog, entries)
+    if err != nil && path != "" {
+        return nil, fmt.Errorf("%s: %v", path, err)
+    }
+    return table, err
+}
+
+// Pick picks the species and level of a wild Pokémon.
+func (t *SpawnTable) Pick(rng *rand.Rand) (*models.Monster, int) {
+    roll := rng.Intn(t.cumulative[len(t.cumulative)-1])
+    i := sort.SearchInts(t.cumulative, roll+1)
+    entry := t.spawns[i].entry
+    return t.spawns[i].species, entry.MinLevel + rng.Intn(entry.MaxLevel-entry.MinLevel+1)
+}
+
+// newWildPokemon creates a wild Pokémon of a species.
+func newWildPokemon(species *models.Monster, level int, rng *rand.Rand) Pokemon {
+    pokemon := Pokemon{ElementalMultiplier: 0.5 + rng.Float64()*0.5}
+    pokemon.setSpecies(species)
+    pokemon.Instance = *models.NewInstance(species, level, rng)
+    return pokemon
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+    "math"
+    "math/rand"
+    "testing"
+
+    "github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+// testCatalog is a catalog of three species, with their base stats.
+func testCatalog() []*models.Monster {
+    return []*models.Monster{
+        {NationalID: 16, Name: "Pidgey", HP: 40, Attack: 45, Defense: 40, SpAtk: 35, SpDef: 35, Speed: 56},
+        {NationalID: 19, Name: "Rattata", HP: 30, Attack: 56, Defense: 35, SpAtk: 25, SpDef: 35, Speed: 72},
+        {NationalID: 150, Name: "Mewtwo", HP: 106, Attack: 110, Defense: 90, SpAtk: 154, SpDef: 90, Speed: 130},
+    }
+}
+
+func TestNewSpawnTable(t *testing.T) {
+    tests := []struct {
+        name    string
+        entries []SpawnEntry
+        wantErr bool
+    }{
+        {name: "no entries"},
+        {name: "valid", entries: []SpawnEntry{{Species: "pidgey", Weight: 10, MinLevel: 2, MaxLevel: 5}, {Species: "Mewtwo", Weight: 0, MinLevel: 70, MaxLevel: 70}}},
+        {name: "negative weight", entries: []SpawnEntry{{Species: "Pidgey", Weight: -1, MinLevel: 2, MaxLevel: 5}}, wantErr: true},
+        {name: "level 0", entries: []SpawnEntry{{Species: "Pidgey", Weight: 1, MinLevel: 0, MaxLevel: 5}}, wantErr: true},
+        {name: "past the top level", entries: []SpawnEntry{{Species: "Pidgey", Weight: 1, MinLevel: 2, MaxLevel: models.MaxLevel + 1}}, wantErr: true},
+        {name: "levels the wrong way round", entries: []SpawnEntry{{Species: "Pidgey", Weight: 1, MinLevel: 5, MaxLevel: 2}}, wantErr: true},
+        {name: "duplicate species", entries: []SpawnEntry{{Species: "Pidgey", Weight: 1, MinLevel: 2, MaxLevel: 5}, {Species: "pidgey", Weight: 2, MinLevel: 2, MaxLevel: 5}}, wantErr: true},
+        {name: "unknown species", entries: []SpawnEntry{{Species: "Agumon", Weight: 1, MinLevel: 2, MaxLevel: 5}}, wantErr: true},
+        {name: "nothing can spawn", entries: []SpawnEntry{
+            {Species: "Pidgey", Weight: 0, MinLevel: 2, MaxLevel: 5},
+            {Species: "Rattata", Weight: 0, MinLevel: 2, MaxLevel: 5},
+            {Species: "Mewtwo", Weight: 0, MinLevel: 70, MaxLevel: 70},
+        }, wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            table, err := NewSpawnTable(testCatalog(), tt.entries)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("NewSpawnTable() error = %v, wantErr %v", err, tt.wantErr)
+            }
+            if !tt.wantErr && table == nil {
+                t.Error("NewSpawnTable() returned no table")
+            }
+        })
+    }
+}
+
+func TestSpawnTable_Pick(t *testing.T) {
+    table, err := NewSpawnTable(testCatalog(), []SpawnEntry{
+        {Species: "Pidgey", Weight: 3, MinLevel: 2, MaxLevel: 4},
+        {Species: "Rattata", Weight: 1, MinLevel: 10, MaxLevel: 10},
+        {Species: "Mewtwo", Weight: 0, MinLevel: 70, MaxLevel: 70},
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    rng := rand.New(rand.NewSource(1))
+    const picks = 20000
+    counts := make(map[string]int)
+    levels := make(map[string]map[int]bool)
+    for i := 0; i < picks; i++ {
+        species, level := table.Pick(rng)
+        counts[species.Name]++
+        if levels[species.Name] == nil {
+            levels[species.Name] = make(map[int]bool)
+        }
+        levels[species.Name][level] = true
+    }
+
+    // Pidgey is picked three times as often as Rattata, and Mewtwo never
+    if got := float64(counts["Pidgey"]) / picks; math.Abs(got-0.75) > 0.02 {
+        t.Errorf("Pidgey was picked %.3f of the time, want about 0.75", got)
+    }
+    if counts["Mewtwo"] > 0 {
+        t.Errorf("Mewtwo was picked %d times with a weight of 0", counts["Mewtwo"])
+    }
+    // Every level of a range comes up, and none outside it
+    tests := []struct {
+        species string
+        want    []int
+    }{
+        {species: "Pidgey", want: []int{2, 3, 4}},
+        {species: "Rattata", want: []int{10}},
+    }
+    for _, tt := range tests {
+        if len(levels[tt.species]) != len(tt.want) {
+            t.Errorf("%s spawned at levels %v, want %v", tt.species, levels[tt.species], tt.want)
+        }
+        for _, level := range tt.want {
+            if !levels[tt.species][level] {
+                t.Errorf("%s never spawned at level %d", tt.species, level)
+            }
+        }
+    }
+}
+
+func Test_defaultSpawn(t *testing.T) {
+    strong := &models.Monster{Name: "Strong", HP: 200, Attack: 200, Defense: 200, SpAtk: 200, SpDef: 200, Speed: 200}
+    tests := []struct {
+        species *models.Monster
+        want    SpawnEntry
+    }{
+        // Weak species are common and low level, with levels no lower than 2
+        {species: testCatalog()[0], want: SpawnEntry{Species: "Pidgey", Weight: 214, MinLevel: 5, MaxLevel: 15}},
+        {species: &models.Monster{Name: "Weak", HP: 10, Attack: 10, Defense: 10, SpAtk: 10, SpDef: 10, Speed: 10}, want: SpawnEntry{Species: "Weak", Weight: 255, MinLevel: 2, MaxLevel: 12}},
+        // Legendaries are rare and high level, with levels no higher than 70
+        {species: testCatalog()[2], want: SpawnEntry{Species: "Mewtwo", Weight: 3, MinLevel: 48, MaxLevel: 58}},
+        {species: strong, want: SpawnEntry{Species: "Strong", Weight: 3, MinLevel: 60, MaxLevel: 70}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.species.Name, func(t *testing.T) {
+            if got := defaultSpawn(tt.species); got != tt.want {
+                t.Errorf("defaultSpawn() = %+v, want %+v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestLoadSpawnTable(t *testing.T) {
+    catalog, err := loadCatalog(dataPath)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // The spawn table pokeCatserver ships with is valid
+    if _, err := LoadSpawnTable("spawns.json", catalog); err != nil {
+        t.Errorf("LoadSpawnTable(spawns.json) = %v", err)
+    }
+    if _, err := LoadSpawnTable("missing.json", catalog); err == nil {
+        t.Error("LoadSpawnTable() of a missing file succeeded")
+    }
+    if _, err := LoadSpawnTable("", catalog); err != nil {
+        t.Errorf("LoadSpawnTable() with no file = %v, want every species by defaultSpawn", err)
+    }
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+    "fmt"
+    "net"
+    "strings"
+)
+
+// viewRadius is how many tiles players see around them each way, for a view
+// 21 tiles across.
+const viewRadius = 10
+
+// Marks drawn over the terrain in a view.
+const (
+    markSelf   = '@'
+    markPlayer = 'P'
+    markWild   = '*'
+)
+
+// inView reports whether a position is in view of one at the centre.
+func inView(centre, pos Position) bool {
+    return abs(pos.X-centre.X) <= viewRadius && abs(pos.Y-centre.Y) <= viewRadius
+}
+
+func abs(n int) int {
+    if n < 0 {
+        return -n
+    }
+    return n
+}
+
+// view draws what a player can see on one line, for pokeCatClient to render:
+// "view <x> <y> <row>|<row>|...", the rows running top to bottom with the
+// player in the middle. Off the map is wall. Callers hold the world lock.
+func (gw *GameWorld) view(player *Player) string {
+    others := make(map[Position]bool)
+    for _, other := range gw.Players {
+        if other != player && inView(player.Position, other.Position) {
+            others[other.Position] = true
+        }
+    }
+
+    rows := make([]string, 0, 2*viewRadius+1)
+    for y := player.Position.Y - viewRadius; y <= player.Position.Y+viewRadius; y++ {
+        row := make([]byte, 0, 2*viewRadius+1)
+        for x := player.Position.X - viewRadius; x <= player.Position.X+viewRadius; x++ {
+            pos := Position{X: x, Y: y}
+            mark := byte(gw.world.Tile(pos))
+            if _, wild := gw.Pokemons[pos]; wild {
+                mark = markWild
+            }
+            if others[pos] {
+                mark = markPlayer
+            }
+            if pos == player.Position {
+                mark = markSelf
+            }
+            row = append(row, mark)
+        }
+        rows = append(rows, string(row))
+    }
+    return fmt.Sprintf("view %d %d %s", player.Position.X, player.Position.Y, strings.Join(rows, "|"))
+}
+
+// pendingView is a view waiting to be sent to a player.
+type pendingView struct {
+    conn net.Conn
+    view string
+}
+
+// views builds a new view for every player who can see one of the
+// positions, after something there changed. Callers hold the world lock, and
+// send the views with sendViews once they have released it, so a slow
+// connection can't hold up the world.
+func (gw *GameWorld) views(positions ...Position) []pendingView {
+    var views []pendingView
+    for _, player := range gw.Players {
+        if player.Conn == nil {
+            continue
+        }
+        for _, pos := range positions {
+            if inView(player.Position, pos) {
+                views = append(views, pendingView{conn: player.Conn, view: gw.view(player)})
+                break
+            }
+        }
+    }
+    return views
+}
+
+// sendViews sends views built by views. Callers don't hold the world lock.
+func sendViews(views []pendingView) {
+    for _, v := range views {
+        fmt.Fprintln(v.conn, v.view)
+    }
+}
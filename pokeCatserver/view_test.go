@@ -0,0 +1,126 @@
+package main
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestGameWorld_view(t *testing.T) {
+    gw := newTestWorld(t, "...\n..\"\n~..\n")
+    player, _ := addTestPlayer(gw, "ash", Position{X: 0, Y: 0})
+    addTestPlayer(gw, "gary", Position{X: 2, Y: 1})
+    gw.Pokemons[Position{X: 1, Y: 2}] = Pokemon{Name: "Pidgey"}
+
+    view := gw.view(player)
+    if !strings.HasPrefix(view, "view 0 0 ") {
+        t.Fatalf("view() = %q, want it to start with the player's position", view)
+    }
+    rows := strings.Split(strings.TrimPrefix(view, "view 0 0 "), "|")
+    if len(rows) != 2*viewRadius+1 {
+        t.Fatalf("view() has %d rows, want %d", len(rows), 2*viewRadius+1)
+    }
+    // The player is in the middle, the map to their right and below, and
+    // everything off the map is wall
+    wall := strings.Repeat("#", 2*viewRadius+1)
+    want := map[int]string{
+        0:  wall,
+        9:  wall,
+        10: "##########@..########",
+        11: "##########..P########",
+        12: "##########~*.########",
+        13: wall,
+        20: wall,
+    }
+    for i, row := range rows {
+        if len(row) != 2*viewRadius+1 {
+            t.Errorf("row %d is %d tiles wide, want %d", i, len(row), 2*viewRadius+1)
+        }
+        if w, ok := want[i]; ok && row != w {
+            t.Errorf("row %d = %q, want %q", i, row, w)
+        }
+    }
+}
+
+func TestGameWorld_views(t *testing.T) {
+    tests := []struct {
+        name string
+        pos  Position
+        want []string
+    }{
+        {name: "seen by both", pos: Position{X: 5, Y: 0}, want: []string{"ash", "gary"}},
+        {name: "seen by one", pos: Position{X: 20, Y: 0}, want: []string{"gary"}},
+        {name: "seen by none", pos: Position{X: 29, Y: 0}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            gw := newTestWorld(t, strings.Repeat(".", 30)+"\n")
+            addTestPlayer(gw, "ash", Position{X: 0, Y: 0})
+            addTestPlayer(gw, "gary", Position{X: 15, Y: 0})
+            // Players without a connection aren't sent views
+            gw.Players["brock"] = &Player{Username: "brock", Position: Position{X: 5, Y: 0}}
+
+            got := make(map[string]bool)
+            for _, v := range gw.views(tt.pos) {
+                for name, player := range gw.Players {
+                    if player.Conn != nil && player.Conn == v.conn {
+                        got[name] = true
+                        if v.view != gw.view(player) {
+                            t.Errorf("%s's view = %q, want %q", name, v.view, gw.view(player))
+                        }
+                    }
+                }
+            }
+            if len(got) != len(tt.want) {
+                t.Errorf("views() went to %v, want %v", got, tt.want)
+            }
+            for _, name := range tt.want {
+                if !got[name] {
+                    t.Errorf("views() went to %v, want %s among them", got, name)
+                }
+            }
+        })
+    }
+}
+
+// lockCheckConn records whether the world lock was held when it was written
+// to.
+type lockCheckConn struct {
+    recordConn
+    gw     *GameWorld
+    locked bool
+}
+
+func (c *lockCheckConn) Write(b []byte) (int, error) {
+    if c.gw.mu.TryLock() {
+        c.gw.mu.Unlock()
+    } else {
+        c.locked = true
+    }
+    return c.recordConn.Write(b)
+}
+
+func TestGameWorld_viewsSentUnlocked(t *testing.T) {
+    gw := newTestWorld(t, "..T"+strings.Repeat(".", 27)+"\n")
+    player, _ := addTestPlayer(gw, "gary", Position{X: 5, Y: 0})
+    conn := &lockCheckConn{gw: gw}
+    player.Conn = conn
+
+    // gary sees ash join and move, and ash run from a wild Pokémon
+    ash, err := gw.AddPlayer("ash", "1", &recordConn{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    gw.MovePlayer("ash", "right")
+    gw.Pokemons[Position{X: 4, Y: 0}] = Pokemon{Name: "Pidgey"}
+    gw.mu.Lock()
+    gw.startEncounter(ash, gw.Pokemons[Position{X: 4, Y: 0}])
+    gw.mu.Unlock()
+    gw.Run("ash")
+
+    if views := strings.Count(conn.String(), "view "); views != 3 {
+        t.Errorf("gary was sent %d views, want 3: %q", views, conn.String())
+    }
+    if conn.locked {
+        t.Error("a view was sent while holding the world lock")
+    }
+}
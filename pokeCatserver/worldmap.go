@@ -0,0 +1,192 @@
+package main
+
+import (
+    "fmt"
+    "math/rand"
+    "os"
+    "slices"
+    "strings"
+
+    "github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+// Tile is a square of the world map, written in map files as its character.
+type Tile byte
+
+const (
+    TilePath  Tile = '.'
+    TileWall  Tile = '#'
+    TileWater Tile = '~'
+    TileGrass Tile = '"'
+    TileCave  Tile = '^'
+    TileTown  Tile = 'T'
+)
+
+// blockedBy says what stops players walking onto the tiles they can't.
+var blockedBy = map[Tile]string{
+    TileWall:  "There's a wall in the way.",
+    TileWater: "You can't swim across the water.",
+}
+
+// Walkable reports whether players can walk onto the tile.
+func (t Tile) Walkable() bool {
+    _, blocked := blockedBy[t]
+    return !blocked
+}
+
+// Biome is the kind of place wild Pokémon of some types live in.
+type Biome string
+
+const (
+    BiomeGrass Biome = "grass"
+    BiomeCave  Biome = "cave"
+    // BiomeShore is any tile players can walk on next to water.
+    BiomeShore Biome = "shore"
+)
+
+// tileBiomes gives the biome of the tiles wild Pokémon live on. Paths and
+// towns have none, so they are safe from wild Pokémon except on the shore.
+var tileBiomes = map[Tile]Biome{
+    TileGrass: BiomeGrass,
+    TileCave:  BiomeCave,
+}
+
+// biomeTypes lists the Pokémon types found in each biome. Every type is found
+// somewhere, so every species has a place to spawn.
+var biomeTypes = map[Biome][]string{
+    BiomeGrass: {"normal", "grass", "bug", "flying", "electric", "fairy", "poison", "fire", "psychic", "fighting"},
+    BiomeCave:  {"rock", "ground", "steel", "dark", "ghost", "dragon", "fighting", "poison", "fire", "ice", "psychic"},
+    BiomeShore: {"water", "ice"},
+}
+
+// WorldMap is the terrain of the world.
+type WorldMap struct {
+    Width, Height int
+    tiles         [][]Tile
+    // spawnable holds the positions of each biome.
+    spawnable map[Biome][]Position
+    towns     []Position
+}
+
+// LoadWorldMap reads a map file: one line of tiles per row, all the same
+// length.
+func LoadWorldMap(path string) (*WorldMap, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    world, err := parseWorldMap(string(data))
+    if err != nil {
+        return nil, fmt.Errorf("%s: %v", path, err)
+    }
+    return world, nil
+}
+
+func parseWorldMap(data string) (*WorldMap, error) {
+    lines := strings.Split(strings.TrimRight(strings.ReplaceAll(data, "\r\n", "\n"), "\n"), "\n")
+    world := &WorldMap{Width: len(lines[0]), Height: len(lines), spawnable: make(map[Biome][]Position)}
+    if world.Width == 0 {
+        return nil, fmt.Errorf("the map is empty")
+    }
+    for y, line := range lines {
+        if len(line) != world.Width {
+            return nil, fmt.Errorf("row %d is %d tiles wide, not %d", y+1, len(line), world.Width)
+        }
+        row := make([]Tile, world.Width)
+        for x := range line {
+            tile := Tile(line[x])
+            switch tile {
+            case TilePath, TileWall, TileWater, TileGrass, TileCave, TileTown:
+            default:
+                return nil, fmt.Errorf("row %d has an unknown tile %q", y+1, line[x])
+            }
+            row[x] = tile
+        }
+        world.tiles = append(world.tiles, row)
+    }
+
+    walkable := false
+    for y := range world.tiles {
+        for x, tile := range world.tiles[y] {
+            pos := Position{X: x, Y: y}
+            walkable = walkable || tile.Walkable()
+            if tile == TileTown {
+                world.towns = append(world.towns, pos)
+            }
+            for _, biome := range world.biomes(pos) {
+                world.spawnable[biome] = append(world.spawnable[biome], pos)
+            }
+        }
+    }
+    if !walkable {
+        return nil, fmt.Errorf("there is nowhere to walk")
+    }
+    return world, nil
+}
+
+// Tile returns the tile at a position. Off the map is all wall.
+func (w *WorldMap) Tile(pos Position) Tile {
+    if pos.X < 0 || pos.Y < 0 || pos.X >= w.Width || pos.Y >= w.Height {
+        return TileWall
+    }
+    return w.tiles[pos.Y][pos.X]
+}
+
+// biomes returns the biomes of a position wild Pokémon can spawn on.
+func (w *WorldMap) biomes(pos Position) []Biome {
+    tile := w.Tile(pos)
+    if !tile.Walkable() {
+        return nil
+    }
+    var biomes []Biome
+    if biome, ok := tileBiomes[tile]; ok {
+        biomes = append(biomes, biome)
+    }
+    for _, next := range []Position{{pos.X, pos.Y - 1}, {pos.X, pos.Y + 1}, {pos.X - 1, pos.Y}, {pos.X + 1, pos.Y}} {
+        if w.Tile(next) == TileWater {
+            biomes = append(biomes, BiomeShore)
+            break
+        }
+    }
+    return biomes
+}
+
+// StartPosition picks where a player starts: in a town if the map has one.
+func (w *WorldMap) StartPosition(rng *rand.Rand) Position {
+    if len(w.towns) > 0 {
+        return w.towns[rng.Intn(len(w.towns))]
+    }
+    for {
+        pos := Position{X: rng.Intn(w.Width), Y: rng.Intn(w.Height)}
+        if w.Tile(pos).Walkable() {
+            return pos
+        }
+    }
+}
+
+// SpawnPosition picks a position in a biome one of the species' types is
+// found in, reporting false if the map has none.
+func (w *WorldMap) SpawnPosition(species *models.Monster, rng *rand.Rand) (Position, bool) {
+    var biomes []Biome
+    total := 0
+    for _, biome := range []Biome{BiomeGrass, BiomeCave, BiomeShore} {
+        for _, t := range species.Types {
+            if slices.Contains(biomeTypes[biome], t) {
+                biomes = append(biomes, biome)
+                total += len(w.spawnable[biome])
+                break
+            }
+        }
+    }
+    if total == 0 {
+        return Position{}, false
+    }
+    i := rng.Intn(total)
+    for _, biome := range biomes {
+        if i < len(w.spawnable[biome]) {
+            return w.spawnable[biome][i], true
+        }
+        i -= len(w.spawnable[biome])
+    }
+    return Position{}, false
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+    "math/rand"
+    "slices"
+    "strings"
+    "testing"
+
+    "github.com/nguyensngoc108/pokemon-game/internal/models"
+)
+
+// testMap has a town, grass, a cave and a pond, walled in. The path and
+// grass tiles next to the pond are shore.
+const testMap = `#######
+#T.."^#
+#.~~"^#
+#.."".#
+#######
+`
+
+func Test_parseWorldMap(t *testing.T) {
+    tests := []struct {
+        name    string
+        tiles   string
+        wantErr string
+    }{
+        {name: "valid", tiles: testMap},
+        {name: "windows line endings", tiles: "..\r\n..\r\n"},
+        {name: "empty", tiles: "", wantErr: "empty"},
+        {name: "ragged rows", tiles: "...\n..\n", wantErr: "row 2"},
+        {name: "unknown tile", tiles: "..\n.x\n", wantErr: "unknown tile 'x'"},
+        {name: "nowhere to walk", tiles: "##\n~~\n", wantErr: "nowhere to walk"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            world, err := parseWorldMap(tt.tiles)
+            if tt.wantErr == "" {
+                if err != nil {
+                    t.Fatalf("parseWorldMap() error = %v", err)
+                }
+                if world.Width == 0 || world.Height == 0 {
+                    t.Errorf("parseWorldMap() map is %dx%d", world.Width, world.Height)
+                }
+                return
+            }
+            if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                t.Errorf("parseWorldMap() error = %v, want one about %q", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestTile_Walkable(t *testing.T) {
+    tests := []struct {
+        tile Tile
+        want bool
+    }{
+        {TilePath, true},
+        {TileGrass, true},
+        {TileCave, true},
+        {TileTown, true},
+        {TileWall, false},
+        {TileWater, false},
+    }
+    for _, tt := range tests {
+        if got := tt.tile.Walkable(); got != tt.want {
+            t.Errorf("Tile(%q).Walkable() = %v, want %v", tt.tile, got, tt.want)
+        }
+    }
+}
+
+func TestWorldMap_biomes(t *testing.T) {
+    world, err := parseWorldMap(testMap)
+    if err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        name string
+        pos  Position
+        want []Biome
+    }{
+        {name: "town", pos: Position{X: 1, Y: 1}},
+        {name: "path", pos: Position{X: 3, Y: 1}, want: []Biome{BiomeShore}},
+        {name: "path away from water", pos: Position{X: 5, Y: 3}},
+        {name: "grass by the water", pos: Position{X: 4, Y: 2}, want: []Biome{BiomeGrass, BiomeShore}},
+        {name: "grass", pos: Position{X: 4, Y: 1}, want: []Biome{BiomeGrass}},
+        {name: "cave", pos: Position{X: 5, Y: 1}, want: []Biome{BiomeCave}},
+        {name: "water", pos: Position{X: 2, Y: 2}},
+        {name: "wall", pos: Position{X: 0, Y: 0}},
+        {name: "off the map", pos: Position{X: -1, Y: 3}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := world.biomes(tt.pos); !slices.Equal(got, tt.want) {
+                t.Errorf("biomes(%+v) = %v, want %v", tt.pos, got, tt.want)
+            }
+        })
+    }
+    if got := world.Tile(Position{X: 7, Y: 0}); got != TileWall {
+        t.Errorf("Tile() off the map = %q, want wall", got)
+    }
+}
+
+func TestWorldMap_SpawnPosition(t *testing.T) {
+    world, err := parseWorldMap(testMap)
+    if err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        name   string
+        types  []string
+        want   []Biome
+        wantOK bool
+    }{
+        {name: "grass type", types: []string{"grass"}, want: []Biome{BiomeGrass}, wantOK: true},
+        {name: "rock type", types: []string{"rock"}, want: []Biome{BiomeCave}, wantOK: true},
+        {name: "water type", types: []string{"water"}, want: []Biome{BiomeShore}, wantOK: true},
+        {name: "either type", types: []string{"water", "ground"}, want: []Biome{BiomeShore, BiomeCave}, wantOK: true},
+        {name: "no known type", types: []string{"shadow"}},
+    }
+    rng := rand.New(rand.NewSource(1))
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            species := &models.Monster{Name: tt.name, Types: tt.types}
+            for i := 0; i < 50; i++ {
+                pos, ok := world.SpawnPosition(species, rng)
+                if ok != tt.wantOK {
+                    t.Fatalf("SpawnPosition() ok = %v, want %v", ok, tt.wantOK)
+                }
+                if !ok {
+                    return
+                }
+                found := false
+                for _, biome := range world.biomes(pos) {
+                    found = found || slices.Contains(tt.want, biome)
+                }
+                if !found {
+                    t.Fatalf("SpawnPosition() = %+v with biomes %v, want one of %v", pos, world.biomes(pos), tt.want)
+                }
+            }
+        })
+    }
+
+    // Players start in a town
+    if got := world.StartPosition(rng); got != (Position{X: 1, Y: 1}) {
+        t.Errorf("StartPosition() = %+v, want the town", got)
+    }
+}
+
+func TestGameWorld_MovePlayer(t *testing.T) {
+    tests := []struct {
+        name      string
+        from      Position
+        direction string
+        wantMoved bool
+        want      Position
+        wantText  string
+    }{
+        {name: "onto a path", from: Position{X: 1, Y: 1}, direction: "right", wantMoved: true, want: Position{X: 2, Y: 1}},
+        {name: "into a wall", from: Position{X: 1, Y: 1}, direction: "up", want: Position{X: 1, Y: 1}, wantText: "There's a wall in the way."},
+        {name: "into water", from: Position{X: 1, Y: 2}, direction: "right", want: Position{X: 1, Y: 2}, wantText: "You can't swim across the water."},
+        {name: "off the map", from: Position{X: 0, Y: 0}, direction: "left", want: Position{X: 0, Y: 0}, wantText: "There's a wall in the way."},
+        {name: "unknown direction", from: Position{X: 1, Y: 1}, direction: "north", want: Position{X: 1, Y: 1}, wantText: `You can't move "north".`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            gw := newTestWorld(t, testMap)
+            player, conn := addTestPlayer(gw, "ash", tt.from)
+            if got := gw.MovePlayer("ash", tt.direction); got != tt.wantMoved {
+                t.Errorf("MovePlayer() = %v, want %v", got, tt.wantMoved)
+            }
+            if player.Position != tt.want {
+                t.Errorf("player is at %+v, want %+v", player.Position, tt.want)
+            }
+            if !strings.Contains(conn.String(), tt.wantText) {
+                t.Errorf("ash was sent %q, want %q in it", conn.String(), tt.wantText)
+            }
+        })
+    }
+
+    gw := newTestWorld(t, testMap)
+    if gw.MovePlayer("gary", "up") {
+        t.Error("MovePlayer() moved a player who hasn't joined")
+    }
+}